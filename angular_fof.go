@@ -0,0 +1,188 @@
+package symfof
+
+import (
+	"fmt"
+	"math"
+)
+
+// AngularFOF runs FOF on points on the celestial sphere, linking points whose
+// great-circle separation is at most theta. ra, dec, and theta are in
+// degrees.
+func AngularFOF(ra, dec []float32, theta float32, nMin int) []int32 {
+	thetas := make([]float64, len(ra))
+	for i := range thetas { thetas[i] = float64(theta)*math.Pi/180 }
+	return angularFOF(ra, dec, thetas, nMin)
+}
+
+// AngularFOFDist runs FOF on points on the celestial sphere where the linking
+// angle of each point is a transverse length b divided by its distance,
+// dist[i]. Two points are linked if their separation is within the larger of
+// their linking angles. ra and dec are in degrees and b and dist must use
+// the same units.
+//
+// Rather than linking a point to the whole sky, an error is returned if any
+// distance is non-positive or non-finite, or if b/dist[i] is not less than
+// pi radians.
+func AngularFOFDist(
+	ra, dec, dist []float32, b float32, nMin int,
+) ([]int32, error) {
+	thetas := make([]float64, len(ra))
+	for i := range thetas {
+		d := float64(dist[i])
+		if !(d > 0) || math.IsInf(d, 0) {
+			return nil, fmt.Errorf("AngularFOFDist was given a distance " +
+				"of %g for point %d, but distances must be positive and " +
+				"finite.", dist[i], i)
+		}
+		thetas[i] = float64(b)/d
+		if !(thetas[i] < math.Pi) {
+			return nil, fmt.Errorf("AngularFOFDist cannot link point %d " +
+				"with b = %g and distance %g, since its linking angle is " +
+				"not less than 180 degrees.", i, b, dist[i])
+		}
+	}
+	return angularFOF(ra, dec, thetas, nMin), nil
+}
+
+// skyGrid bins points on the sphere into declination bands which are split
+// into right ascension cells. Cells are about as wide as they are tall at
+// every declination, so there are few cells near the poles.
+type skyGrid struct {
+	// bandHeight and the declinations of points are in radians from the
+	// south pole.
+	bandHeight float64
+	// nRA[b] is the number of cells in band b and bandStart[b] is the index
+	// of its first cell.
+	nRA, bandStart []int
+	// The points in cell c are items[cellStart[c]: cellStart[c+1]].
+	cellStart []int
+	items     []int32
+}
+
+// newSkyGrid bins points with the given polar angles and right ascensions,
+// both in radians, into cells which are at least theta wide.
+func newSkyGrid(polar, phi []float64, theta float64) *skyGrid {
+	nBand := int(math.Pi/theta)
+	if nBand < 1 { nBand = 1 }
+	g := &skyGrid{
+		bandHeight: math.Pi/float64(nBand),
+		nRA: make([]int, nBand), bandStart: make([]int, nBand + 1),
+	}
+
+	for b := 0; b < nBand; b++ {
+		// The widest circle of latitude in the band sets the cell width.
+		lo, hi := float64(b)*g.bandHeight, float64(b + 1)*g.bandHeight
+		sinMax := math.Max(math.Sin(lo), math.Sin(hi))
+		if lo < math.Pi/2 && hi > math.Pi/2 { sinMax = 1 }
+
+		g.nRA[b] = int(2*math.Pi*sinMax/theta)
+		if g.nRA[b] < 1 { g.nRA[b] = 1 }
+		g.bandStart[b+1] = g.bandStart[b] + g.nRA[b]
+	}
+
+	cells := make([]int, len(polar))
+	g.cellStart = make([]int, g.bandStart[nBand] + 1)
+	for i := range polar {
+		cells[i] = g.cell(polar[i], phi[i])
+		g.cellStart[cells[i] + 1]++
+	}
+	for c := 1; c < len(g.cellStart); c++ {
+		g.cellStart[c] += g.cellStart[c-1]
+	}
+
+	g.items = make([]int32, len(polar))
+	next := append([]int{ }, g.cellStart...)
+	for i, c := range cells {
+		g.items[next[c]] = int32(i)
+		next[c]++
+	}
+
+	return g
+}
+
+// band returns the band containing the given polar angle.
+func (g *skyGrid) band(polar float64) int {
+	b := int(polar/g.bandHeight)
+	if b < 0 { return 0 }
+	if b >= len(g.nRA) { return len(g.nRA) - 1 }
+	return b
+}
+
+// raCell returns the unwrapped index of the cell in band b containing phi.
+func (g *skyGrid) raCell(b int, phi float64) int {
+	return int(math.Floor(phi/(2*math.Pi)*float64(g.nRA[b])))
+}
+
+// cell returns the index of the cell containing a point.
+func (g *skyGrid) cell(polar, phi float64) int {
+	b := g.band(polar)
+	c := g.raCell(b, phi) % g.nRA[b]
+	if c < 0 { c += g.nRA[b] }
+	return g.bandStart[b] + c
+}
+
+// angularFOF links points which are within the larger of their two linking
+// angles, thetas, which are in radians.
+func angularFOF(ra, dec []float32, thetas []float64, nMin int) []int32 {
+	uf := NewUnionFinder(int32(len(ra)))
+	if len(ra) == 0 { return groupLabels(uf, nMin) }
+
+	polar, phi := make([]float64, len(ra)), make([]float64, len(ra))
+	vec := make([][3]float64, len(ra))
+	thetaMax := 0.0
+	for i := range ra {
+		phi[i] = math.Mod(float64(ra[i])*math.Pi/180, 2*math.Pi)
+		if phi[i] < 0 { phi[i] += 2*math.Pi }
+		polar[i] = math.Pi/2 + float64(dec[i])*math.Pi/180
+		sinPolar := math.Sin(polar[i])
+		vec[i] = [3]float64{
+			sinPolar*math.Cos(phi[i]), sinPolar*math.Sin(phi[i]),
+			-math.Cos(polar[i]),
+		}
+		thetaMax = math.Max(thetaMax, thetas[i])
+	}
+
+	// Cells are never smaller than needed to hold a few points each, which
+	// also keeps tiny linking lengths from making huge grids.
+	g := newSkyGrid(polar, phi,
+		math.Max(thetaMax, math.Pi/math.Sqrt(float64(len(ra)))))
+
+	// Pad the search slightly so rounding can't hide pairs right at the
+	// linking length.
+	search := thetaMax*(1 + 1e-6)
+	for i := range ra {
+		b0, b1 := g.band(polar[i] - search), g.band(polar[i] + search)
+
+		// The range of right ascensions within the search radius of the
+		// point. The whole circle is searched if the search cap contains a
+		// pole.
+		dPhi := math.Pi
+		if polar[i] - search > 0 && polar[i] + search < math.Pi {
+			sinRatio := math.Sin(search)/math.Sin(polar[i])
+			dPhi = math.Asin(math.Min(sinRatio, 1))
+		}
+
+		for b := b0; b <= b1; b++ {
+			c0, c1 := g.raCell(b, phi[i] - dPhi), g.raCell(b, phi[i] + dPhi)
+			if c1 - c0 + 1 > g.nRA[b] { c0, c1 = 0, g.nRA[b] - 1 }
+
+			for c := c0; c <= c1; c++ {
+				cw := c % g.nRA[b]
+				if cw < 0 { cw += g.nRA[b] }
+				cell := g.bandStart[b] + cw
+				items := g.items[g.cellStart[cell]: g.cellStart[cell+1]]
+
+				for _, j := range items {
+					if j <= int32(i) { continue }
+
+					theta := math.Max(thetas[i], thetas[j])
+					dot := vec[i][0]*vec[j][0] + vec[i][1]*vec[j][1] +
+						vec[i][2]*vec[j][2]
+					if dot >= math.Cos(theta) { uf.Union(int32(i), j) }
+				}
+			}
+		}
+	}
+
+	return groupLabels(uf, nMin)
+}
@@ -0,0 +1,98 @@
+package symfof
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// bruteAngularFOF links every pair of points within the larger of their
+// linking angles, thetas, which are in degrees.
+func bruteAngularFOF(ra, dec, thetas []float32, nMin int) []int32 {
+	uf := NewUnionFinder(int32(len(ra)))
+	for i := range ra {
+		for j := i + 1; j < len(ra); j++ {
+			theta := math.Max(float64(thetas[i]), float64(thetas[j]))
+			if angularSep(ra[i], dec[i], ra[j], dec[j]) <= theta {
+				uf.Union(int32(i), int32(j))
+			}
+		}
+	}
+	return groupLabels(uf, nMin)
+}
+
+// angularSep returns the great-circle separation between two points in
+// degrees.
+func angularSep(ra1, dec1, ra2, dec2 float32) float64 {
+	x1 := SkyToCartesian(1, ra1, dec1)
+	x2 := SkyToCartesian(1, ra2, dec2)
+	dot := 0.0
+	for k := 0; k < 3; k++ { dot += float64(x1[k])*float64(x2[k]) }
+	return math.Acos(math.Min(dot, 1))*180/math.Pi
+}
+
+// randomSky returns n points spread uniformly over the sphere.
+func randomSky(n int, seed int64) (ra, dec []float32) {
+	rng := rand.New(rand.NewSource(seed))
+	ra, dec = make([]float32, n), make([]float32, n)
+	for i := range ra {
+		ra[i] = 360*rng.Float32()
+		dec[i] = float32(math.Asin(2*rng.Float64() - 1)*180/math.Pi)
+	}
+	return ra, dec
+}
+
+func TestAngularFOF(t *testing.T) {
+	// Pairs which cross RA = 0 and the north pole.
+	ra := []float32{ 359.5, 0.3, 0, 180, 90, 90 }
+	dec := []float32{ 10, 10, 89.6, 89.6, -30, -30.8 }
+	groups := AngularFOF(ra, dec, 1, 2)
+	for i := 0; i < len(ra); i += 2 {
+		if groups[i] == -1 || groups[i] != groups[i+1] {
+			t.Errorf("Expected points %d and %d to be linked, got groups " +
+				"%d and %d.", i, i+1, groups[i], groups[i+1])
+		}
+	}
+	if groups[0] == groups[2] || groups[2] == groups[4] {
+		t.Errorf("Expected three separate groups, got %d.", groups)
+	}
+
+	ra, dec = randomSky(2000, 29)
+	thetas := make([]float32, len(ra))
+	for i := range thetas { thetas[i] = 3 }
+	if !samePartition(AngularFOF(ra, dec, 3, 2),
+		bruteAngularFOF(ra, dec, thetas, 2)) {
+		t.Errorf("AngularFOF groups do not match brute force linking.")
+	}
+}
+
+func TestAngularFOFDist(t *testing.T) {
+	ra, dec := randomSky(2000, 31)
+	rng := rand.New(rand.NewSource(32))
+	dist, thetas := make([]float32, len(ra)), make([]float32, len(ra))
+	b := float32(5)
+	for i := range dist {
+		dist[i] = 50 + 150*rng.Float32()
+		thetas[i] = b/dist[i]*180/math.Pi
+	}
+
+	groups, err := AngularFOFDist(ra, dec, dist, b, 2)
+	if err != nil {
+		t.Fatalf("AngularFOFDist failed: %s", err.Error())
+	}
+	if !samePartition(groups, bruteAngularFOF(ra, dec, thetas, 2)) {
+		t.Errorf("AngularFOFDist groups do not match brute force linking.")
+	}
+
+	// Distances which would give a point a linking angle of the whole sky
+	// are errors.
+	inf := float32(math.Inf(+1))
+	nan := float32(math.NaN())
+	for _, d := range []float32{ 0, -10, inf, nan, 1 } {
+		bad := append([]float32{ }, dist...)
+		bad[17] = d
+		if _, err := AngularFOFDist(ra, dec, bad, b, 2); err == nil {
+			t.Errorf("Expected an error for a distance of %g.", d)
+		}
+	}
+}
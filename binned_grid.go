@@ -1,6 +1,7 @@
 package symfof
 
 import (
+	"math"
 	"slices"
 )
 
@@ -14,6 +15,7 @@ import (
 // (3) It does not handle periodic checks. If the particles are from a 
 // periodic box, they need to have been shifted into their most contiguous
 // frame and must be smaller than grid minus a single grid cell.
+// BoxParticles converts points in a Box into these code units.
 //
 // (4) The upper bounf of the grid is exclusive, not inclusive. The lower bound
 // is inclusive.
@@ -43,6 +45,42 @@ type BinnedGrid interface {
 	Get(idx [3]int64, out ...[]Particle) []Particle
 }
 
+// BoxParticles converts the points x, which are in box, into Particles in
+// the code units used by a BinnedGrid with span cells along each axis. Each
+// Particle's ID is the index of its point. Points are wrapped into the box
+// along periodic axes and moved onto its edge cells along non-periodic ones,
+// so box should enclose every point if the positions will be used for
+// anything other than binning. Cells are box.Width[k]/span[k] wide, which
+// can be given to Pairer.CellWidth.
+func BoxParticles(box *Box, x [][3]float32, span [3]int64) []Particle {
+	p := make([]Particle, len(x))
+	for i := range x {
+		p[i].ID = uint64(i)
+		for k := 0; k < 3; k++ {
+			p[i].X[k] = boxCellUnits(box, x[i][k], k, span[k])
+		}
+	}
+	return p
+}
+
+// boxCellUnits converts the position x along axis k of box into the code
+// units of a grid with n cells along that axis.
+func boxCellUnits(box *Box, x float32, k int, n int64) float32 {
+	nf := float32(n)
+	u := (x - box.Origin[k])/(box.Width[k]/nf)
+	if box.Periodic[k] {
+		if u < 0 { u += nf }
+		if u >= nf { u -= nf }
+		// Rounding can push points sitting right at an edge out of the box.
+		if u < 0 || u >= nf { u = 0 }
+		return u
+	}
+
+	if u < 0 { return 0 }
+	if u >= nf { return math.Nextafter32(nf, 0) }
+	return u
+}
+
 /////////////////////////////
 // ArrayListGrid functions //
 /////////////////////////////
@@ -150,3 +150,31 @@ func TestResize(t *testing.T) {
 		}
 	}
 }
+
+func TestBoxParticles(t *testing.T) {
+	box := &Box{
+		Origin: [3]float32{ -10, 0, 0 },
+		Width: [3]float32{ 20, 30, 10 },
+		Periodic: [3]bool{ true, false, true },
+	}
+	x := [][3]float32{
+		{-10, 0, 0}, {9.9, 29.9, 9.9}, {12, 15, -1},
+		{0, -5, 0}, {0, 35, 10},
+	}
+	span := [3]int64{ 4, 3, 2 }
+	exp := [][3]int64{
+		{0, 0, 0}, {3, 2, 1}, {0, 1, 1},
+		{2, 0, 0}, {2, 2, 0},
+	}
+
+	p := BoxParticles(box, x, span)
+	for i := range p {
+		idx := [3]int64{
+			int64(p[i].X[0]), int64(p[i].X[1]), int64(p[i].X[2]),
+		}
+		if p[i].ID != uint64(i) || idx != exp[i] {
+			t.Errorf("%d) expected point %g with ID %d to be in cell %d, " +
+				"got ID %d and cell %d.", i, x[i], i, exp[i], p[i].ID, idx)
+		}
+	}
+}
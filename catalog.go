@@ -0,0 +1,145 @@
+package symfof
+
+import (
+	"math"
+	"slices"
+)
+
+// Halo summarizes the properties of a single FOF group.
+type Halo struct {
+	// ID is the index of the halo within its Catalog and Root is the label
+	// the group had in the groups array the Catalog was built from.
+	ID, Root int32
+	// N is the number of member particles and Mass is their total mass.
+	N    int32
+	Mass float32
+	// X is the center of mass, which accounts for periodic boundaries, and
+	// V is the bulk velocity.
+	X, V [3]float32
+	// SigmaV is the 3D velocity dispersion around V.
+	SigmaV float32
+	// RMax is the distance from X to the furthest member.
+	RMax float32
+	// CentralID is the Particle.ID of the member closest to X.
+	CentralID uint64
+}
+
+// Catalog is a collection of Halos built from a set of FOF groups. Halos are
+// in the same order as the IDs given by RelabelGroups: by decreasing size,
+// with ties broken by the smallest member Particle.ID. This means the same
+// groups always get the same IDs regardless of the order the particles are
+// in.
+type Catalog struct {
+	// L is the width of the periodic box and Mp is the mass of a single
+	// particle.
+	L, Mp float32
+	Halos []Halo
+	// The members of halo i are members[start[i]: start[i+1]].
+	start, members []int32
+}
+
+// NewCatalog creates a Catalog from the labels returned by FOF, where
+// groups[i] is the group of the particle p[i] and -1 means that a particle
+// isn't in any group. Every particle has mass mp.
+func NewCatalog(L, mp float32, groups []int32, p []Particle) *Catalog {
+	ids := make([]uint64, len(p))
+	for i := range p { ids[i] = p[i].ID }
+	roots := groupOrder(groups, ids)
+
+	return newCatalogFromRoots(L, mp, groups, p, roots)
+}
+
+// newCatalogFromRoots creates a Catalog where halo i is the group with label
+// roots[i].
+func newCatalogFromRoots(
+	L, mp float32, groups []int32, p []Particle, roots []int32,
+) *Catalog {
+	c := &Catalog{ L: L, Mp: mp, Halos: make([]Halo, len(roots)) }
+
+	index := make(map[int32]int32, len(roots))
+	for i, g := range roots { index[g] = int32(i) }
+
+	// Counting sort the members into halo order.
+	c.start = make([]int32, len(roots) + 1)
+	for _, g := range groups {
+		if g != -1 { c.start[index[g] + 1]++ }
+	}
+	for i := 1; i < len(c.start); i++ { c.start[i] += c.start[i-1] }
+
+	c.members = make([]int32, c.start[len(roots)])
+	next := slices.Clone(c.start[:len(roots)])
+	for i, g := range groups {
+		if g == -1 { continue }
+		h := index[g]
+		c.members[next[h]] = int32(i)
+		next[h]++
+	}
+
+	for i := range c.Halos {
+		c.Halos[i] = c.summarize(int32(i), roots[i], p)
+	}
+
+	return c
+}
+
+// summarize computes the properties of halo i.
+func (c *Catalog) summarize(i, root int32, p []Particle) Halo {
+	members := c.Members(i)
+	h := Halo{
+		ID: i, Root: root, N: int32(len(members)),
+		Mass: c.Mp*float32(len(members)),
+	}
+	n := float64(len(members))
+
+	// Positions are measured relative to the first member so that groups
+	// which cross the box edge don't get split.
+	ref := p[members[0]].X
+	dx, v := [3]float64{ }, [3]float64{ }
+	for _, j := range members {
+		for k := 0; k < 3; k++ {
+			dx[k] += float64(SymBound(p[j].X[k] - ref[k], c.L))
+			v[k] += float64(p[j].V[k])
+		}
+	}
+	for k := 0; k < 3; k++ {
+		h.X[k] = Bound(ref[k] + float32(dx[k]/n), c.L)
+		h.V[k] = float32(v[k]/n)
+	}
+
+	sigma2, minDr2 := float64(0), float32(math.Inf(+1))
+	for _, j := range members {
+		dr2 := float32(0)
+		for k := 0; k < 3; k++ {
+			dx := SymBound(p[j].X[k] - h.X[k], c.L)
+			dv := float64(p[j].V[k] - h.V[k])
+			dr2 += dx*dx
+			sigma2 += dv*dv
+		}
+
+		if dr2 > h.RMax*h.RMax {
+			h.RMax = float32(math.Sqrt(float64(dr2)))
+		}
+		if dr2 < minDr2 {
+			minDr2, h.CentralID = dr2, p[j].ID
+		}
+	}
+	h.SigmaV = float32(math.Sqrt(sigma2/n))
+
+	return h
+}
+
+// Members returns the indices of the particles in halo i. The returned array
+// is an internal buffer, so please treat it kindly.
+func (c *Catalog) Members(i int32) []int32 {
+	return c.members[c.start[i]: c.start[i+1]]
+}
+
+// Particles copies the members of halo i out of p and into buf. buf may be
+// resized, so use the returned array instead of continuing to reference it.
+func (c *Catalog) Particles(i int32, p, buf []Particle) []Particle {
+	buf = buf[:0]
+	for _, j := range c.Members(i) {
+		buf = append(buf, p[j])
+	}
+	return buf
+}
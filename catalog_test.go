@@ -0,0 +1,65 @@
+package symfof
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	L, mp := float32(100), float32(2)
+	p := []Particle{
+		{ 7, [3]float32{ 50, 50, 50 }, [3]float32{ 1, 0, 0 } },
+		{ 3, [3]float32{ 99, 10, 10 }, [3]float32{ 0, 2, 0 } },
+		{ 8, [3]float32{ 52, 50, 50 }, [3]float32{ -1, 0, 0 } },
+		{ 4, [3]float32{ 1, 10, 10 }, [3]float32{ 0, 4, 0 } },
+		{ 9, [3]float32{ 51, 50, 50 }, [3]float32{ 3, 0, 0 } },
+		{ 1, [3]float32{ 20, 20, 20 }, [3]float32{ 0, 0, 0 } },
+	}
+	groups := []int32{ 2, 3, 2, 3, 2, -1 }
+
+	c := NewCatalog(L, mp, groups, p)
+	if len(c.Halos) != 2 {
+		t.Fatalf("Expected 2 halos, got %d.", len(c.Halos))
+	}
+
+	// The larger halo comes first.
+	exp := []Halo{
+		{ ID: 0, Root: 2, N: 3, Mass: 6, X: [3]float32{ 51, 50, 50 },
+			V: [3]float32{ 1, 0, 0 }, SigmaV: float32(math.Sqrt(8.0/3)),
+			RMax: 1, CentralID: 9 },
+		{ ID: 1, Root: 3, N: 2, Mass: 4, X: [3]float32{ 0, 10, 10 },
+			V: [3]float32{ 0, 3, 0 }, SigmaV: 1, RMax: 1, CentralID: 3 },
+	}
+	members := [][]int32{ { 0, 2, 4 }, { 1, 3 } }
+
+	for i := range exp {
+		h := c.Halos[i]
+		if h.ID != exp[i].ID || h.Root != exp[i].Root || h.N != exp[i].N ||
+			h.CentralID != exp[i].CentralID || !almostEq(h.Mass, exp[i].Mass) ||
+			!almostEq(h.SigmaV, exp[i].SigmaV) || !almostEq(h.RMax, exp[i].RMax) {
+			t.Errorf("%d) Expected halo %+v, got %+v.", i, exp[i], h)
+		}
+		for k := 0; k < 3; k++ {
+			if !almostEq(SymBound(h.X[k] - exp[i].X[k], L), 0) ||
+				!almostEq(h.V[k], exp[i].V[k]) {
+				t.Errorf("%d) Expected halo %+v, got %+v.", i, exp[i], h)
+			}
+		}
+
+		if !Int32Eq(c.Members(int32(i)), members[i]) {
+			t.Errorf("%d) Expected members %d, got %d.",
+				i, members[i], c.Members(int32(i)))
+		}
+		buf := c.Particles(int32(i), p, nil)
+		for j := range buf {
+			if buf[j] != p[members[i][j]] {
+				t.Errorf("%d) Particles()[%d] = %v, expected %v.",
+					i, j, buf[j], p[members[i][j]])
+			}
+		}
+	}
+}
+
+func almostEq(x, y float32) bool {
+	return math.Abs(float64(x - y)) < 1e-4
+}
@@ -0,0 +1,276 @@
+package symfof
+
+import (
+	"fmt"
+	"math"
+)
+
+// halfShell lists the 13 neighbouring cells which come after a cell in C
+// order. Comparing every cell against itself and these neighbours visits each
+// pair of adjacent cells exactly once.
+var halfShell = [13][3]int64{
+	{1, 0, 0},
+	{-1, 1, 0}, {0, 1, 0}, {1, 1, 0},
+	{-1, -1, 1}, {0, -1, 1}, {1, -1, 1},
+	{-1, 0, 1}, {0, 0, 1}, {1, 0, 1},
+	{-1, 1, 1}, {0, 1, 1}, {1, 1, 1},
+}
+
+// CellLinker finds every pair of points in a box which are closer than a
+// linking length. Points are binned into cells that are at least one linking
+// length wide, so each cell only needs to be compared against itself and its
+// half-shell of neighbours. There are never many more cells than points, so
+// short linking lengths can make cells much wider than r.
+//
+// Internally, points are stored as Particles in the code units made by
+// BoxParticles, where cells have unit width along each axis, and each
+// Particle's ID is the index of the original point. Code units are only used
+// to find candidate pairs: distances are always measured between the
+// original points, so pairs at exactly r are linked the same way FOF links
+// them.
+type CellLinker struct {
+	// Cells is the number of cells along each axis of the box.
+	Cells [3]int64
+	// box is the box that the linker was created with, but with its
+	// non-periodic axes widened to enclose every point.
+	box  Box
+	grid CountingSortGrid
+	// x is the original points.
+	x [][3]float32
+	// cw is the cell width along each axis, r is the linking length and
+	// rPad is the slightly larger radius used to find candidate pairs.
+	cw      [3]float32
+	r, rPad float32
+}
+
+// cellPairer holds the buffers needed by a single goroutine while it walks
+// over the cells of a CellLinker.
+type cellPairer struct {
+	pair Pairer
+	buf  []Particle
+}
+
+// maxCellsPerPoint limits the number of cells in a CellLinker relative to
+// the number of points.
+const maxCellsPerPoint = 2
+
+// NewCellLinker bins the points x, which are in a periodic box of width L,
+// into cells that are at least r wide. r must be less than L/2.
+func NewCellLinker(L float32, x [][3]float32, r float32) *CellLinker {
+	return NewCellLinkerBox(CubicBox(L), x, r)
+}
+
+// NewCellLinkerBox bins the points x, which are in box, into cells that are
+// at least r wide. r must be less than box.MaxRadius(). Points may be
+// outside the box along non-periodic axes.
+func NewCellLinkerBox(box *Box, x [][3]float32, r float32) *CellLinker {
+	if r >= box.MaxRadius() {
+		panic(fmt.Sprintf("CellLinker cannot use a linking length of %g " +
+			"in a box with widths %g.", r, box.Width))
+	}
+
+	cl := &CellLinker{ box: *box }
+	if len(x) > 0 {
+		fb := PointBoundsNonPeriodic(x)
+		for k := 0; k < 3; k++ {
+			if cl.box.Periodic[k] { continue }
+			lo := min(cl.box.Origin[k], fb.Origin[k])
+			hi := max(cl.box.Origin[k] + cl.box.Width[k],
+				fb.Origin[k] + fb.Span[k])
+			cl.box.Origin[k], cl.box.Width[k] = lo, openWidth(hi - lo)
+		}
+	}
+
+	nCells := float64(1)
+	for k := 0; k < 3; k++ {
+		cl.Cells[k] = max(int64(cl.box.Width[k] / r), 1)
+		nCells *= float64(cl.Cells[k])
+	}
+	// With fewer than three cells along an axis, a cell's neighbours on
+	// either side are the same cell, but they're shifted by different
+	// periodic images, so each pair is still found once.
+	if maxCells := float64(maxCellsPerPoint*len(x)); nCells > maxCells {
+		shrink := math.Cbrt(nCells/maxCells)
+		for k := 0; k < 3; k++ {
+			cl.Cells[k] = max(int64(float64(cl.Cells[k])/shrink), 1)
+		}
+	}
+
+	for k := 0; k < 3; k++ {
+		cl.cw[k] = cl.box.Width[k] / float32(cl.Cells[k])
+	}
+	cl.x, cl.r = x, r
+	// Rounding in code units can move a separation by a few ulps of the
+	// box width, so candidates are found with a slightly larger radius.
+	wMax := max(cl.box.Width[0], cl.box.Width[1], cl.box.Width[2])
+	cl.rPad = r*(1 + 1e-5) + wMax*1e-6
+
+	cl.grid.Resize(cl.Cells)
+	cl.grid.Bin(BoxParticles(&cl.box, x, cl.Cells))
+
+	// Sorting the cells lets the Pairer skip most distance checks.
+	pair := &Pairer{ }
+	for i := int64(0); i < cl.Cells[0]*cl.Cells[1]*cl.Cells[2]; i++ {
+		pair.SortParticles(cl.grid.Data[cl.grid.BinEdges[i]:
+			cl.grid.BinEdges[i+1]], 0)
+	}
+
+	return cl
+}
+
+// Link unions every pair of points closer than the linking length.
+func (cl *CellLinker) Link(uf *UnionFinder) {
+	cp := &cellPairer{ }
+	cl.linkCells(0, cl.Cells[2], cp, func(i, j int32, local bool) {
+		uf.Union(i, j)
+	})
+}
+
+// linkCells calls link on every pair of points closer than the linking
+// length where the first point is in a cell whose z-index is in [z0, z1).
+// local is true if the second point is also in that range of cells.
+func (cl *CellLinker) linkCells(
+	z0, z1 int64, cp *cellPairer, link func(i, j int32, local bool),
+) {
+	nc := cl.Cells
+	cp.pair.CellWidth = cl.cw
+	for iz := z0; iz < z1; iz++ {
+		for iy := int64(0); iy < nc[1]; iy++ {
+			for ix := int64(0); ix < nc[0]; ix++ {
+				home := cl.grid.Get([3]int64{ix, iy, iz})
+				if len(home) == 0 { continue }
+
+				i1, i2 := cp.pair.FindPairsOneCell(home, cl.rPad, 0)
+				for k := range i1 {
+					i, j := int32(home[i1[k]].ID), int32(home[i2[k]].ID)
+					if cl.within(i, j) { link(i, j, true) }
+				}
+
+			shellLoop:
+				for _, d := range halfShell {
+					idx := [3]int64{ix + d[0], iy + d[1], iz + d[2]}
+					shift := [3]float32{ }
+					for k := 0; k < 3; k++ {
+						if idx[k] >= 0 && idx[k] < nc[k] { continue }
+						if !cl.box.Periodic[k] { continue shellLoop }
+
+						if idx[k] >= nc[k] {
+							idx[k] -= nc[k]
+							shift[k] = float32(nc[k])
+						} else {
+							idx[k] += nc[k]
+							shift[k] = -float32(nc[k])
+						}
+					}
+
+					nbr := cl.grid.Get(idx)
+					if len(nbr) == 0 { continue }
+
+					if shift != [3]float32{ } {
+						cp.buf = append(cp.buf[:0], nbr...)
+						for j := range cp.buf {
+							for k := 0; k < 3; k++ {
+								cp.buf[j].X[k] += shift[k]
+							}
+						}
+						nbr = cp.buf
+					}
+
+					local := idx[2] >= z0 && idx[2] < z1
+					i1, i2 := cp.pair.FindPairsTwoCells(home, nbr, cl.rPad, 0)
+					for k := range i1 {
+						i, j := int32(home[i1[k]].ID), int32(nbr[i2[k]].ID)
+						if cl.within(i, j) { link(i, j, local) }
+					}
+				}
+			}
+		}
+	}
+}
+
+// within returns true if the points i and j are no further apart than the
+// linking length. It measures distances in the same way that FOF does.
+func (cl *CellLinker) within(i, j int32) bool {
+	dr2 := float32(0)
+	for k := 0; k < 3; k++ {
+		dx := cl.box.SymBound(cl.x[i][k] - cl.x[j][k], k)
+		dr2 += dx*dx
+	}
+	return cl.r*cl.r >= dr2
+}
+
+// Nearest returns the index of the closest point to pos which is no further
+// than r away. If there is no such point, -1 is returned.
+func (cl *CellLinker) Nearest(pos [3]float32, r float32) int32 {
+	nc := cl.Cells
+	home := [3]int64{ }
+	for k := 0; k < 3; k++ {
+		pos[k] = cl.box.Bound(pos[k], k)
+		home[k] = int64(boxCellUnits(&cl.box, pos[k], k, nc[k]))
+	}
+
+	best, bestDr2 := int32(-1), r*r
+	for dz := int64(-1); dz <= 1; dz++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for dx := int64(-1); dx <= 1; dx++ {
+				idx := [3]int64{ home[0] + dx, home[1] + dy, home[2] + dz }
+				ok := true
+				for k := 0; k < 3; k++ {
+					if idx[k] >= 0 && idx[k] < nc[k] { continue }
+					if !cl.box.Periodic[k] {
+						ok = false
+					} else if idx[k] >= nc[k] {
+						idx[k] -= nc[k]
+					} else {
+						idx[k] += nc[k]
+					}
+				}
+				if !ok { continue }
+
+				for _, p := range cl.grid.Get(idx) {
+					dr2 := float32(0)
+					for k := 0; k < 3; k++ {
+						d := cl.box.SymBound(cl.x[p.ID][k] - pos[k], k)
+						dr2 += d*d
+					}
+					if dr2 <= bestDr2 {
+						best, bestDr2 = int32(p.ID), dr2
+					}
+				}
+			}
+		}
+	}
+
+	return best
+}
+
+// CellFOF runs the same FOF algorithm as FOF, but finds pairs with a
+// CellLinker instead of searching around every point with a Finder. Centers
+// are assigned to the group of the nearest point within r.
+func CellFOF(
+	L float32, x, cen [][3]float32, r float32, nMin int,
+) (groups, cenGroups []int32) {
+	return CellFOFBox(CubicBox(L), x, cen, r, nMin)
+}
+
+// CellFOFBox runs CellFOF on points inside an arbitrary box.
+func CellFOFBox(
+	box *Box, x, cen [][3]float32, r float32, nMin int,
+) (groups, cenGroups []int32) {
+	cl := NewCellLinkerBox(box, x, r)
+	uf := NewUnionFinder(int32(len(x)))
+	cl.Link(uf)
+
+	groups = groupLabels(uf, nMin)
+
+	cenGroups = make([]int32, len(cen))
+	for i := range cenGroups {
+		if j := cl.Nearest(cen[i], r); j == -1 {
+			cenGroups[i] = -1
+		} else {
+			cenGroups[i] = groups[j]
+		}
+	}
+
+	return groups, cenGroups
+}
@@ -0,0 +1,115 @@
+package symfof
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// randomPoints generates n uniform random points in a box of width L.
+func randomPoints(n int, L float32, seed int64) [][3]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	x := make([][3]float32, n)
+	for i := range x {
+		for k := 0; k < 3; k++ {
+			x[i][k] = rng.Float32() * L
+		}
+	}
+	return x
+}
+
+// samePartition returns true if two labelings split points into the same
+// groups, regardless of which label each group is given.
+func samePartition(a, b []int32) bool {
+	if len(a) != len(b) { return false }
+	ab, ba := map[int32]int32{ }, map[int32]int32{ }
+	for i := range a {
+		if (a[i] == -1) != (b[i] == -1) { return false }
+		if a[i] == -1 { continue }
+
+		if j, ok := ab[a[i]]; ok && j != b[i] { return false }
+		if j, ok := ba[b[i]]; ok && j != a[i] { return false }
+		ab[a[i]], ba[b[i]] = b[i], a[i]
+	}
+	return true
+}
+
+func TestCellFOF(t *testing.T) {
+	L := float32(200)
+	x := [][3]float32{
+		{100, 100, 100}, {101, 100, 100}, {102, 100, 100}, {103, 100, 100},
+		{150, 199, 20}, {150, 0, 20}, {150, 1, 20},
+		{1, 2, 3},
+		{199.5, 50, 50}, {0.5, 50, 50}, {1.5, 50, 50},
+		{120, 120, 120}, {120, 120, 120},
+	}
+	cen := [][3]float32{ {100.5, 100, 100}, {150.5, 1.5, 20.5}, {120, 120, 120} }
+
+	groups, cenGroups := CellFOF(L, x, cen, 2, 3)
+	expGroups := []int32{ 0, 0, 0, 0, 4, 4, 4, -1, 8, 8, 8, -1, -1 }
+	if !samePartition(groups, expGroups) {
+		t.Errorf("Expected groups %d, got %d", expGroups, groups)
+	}
+
+	expCen := []int32{ groups[0], groups[4], -1 }
+	if !Int32Eq(cenGroups, expCen) {
+		t.Errorf("Expected cenGroups %d, got %d", expCen, cenGroups)
+	}
+}
+
+func TestCellFOFMatchesFOF(t *testing.T) {
+	L := float32(40)
+	tests := []struct{
+		n int
+		r float32
+	} {
+		{ 100, 5 }, { 2000, 1.3 }, { 5000, 1 }, { 5000, 12 },
+		// Fewer than three cells, either from a long linking length or from
+		// too few points.
+		{ 200, 15 }, { 200, 19.5 }, { 3, 12 }, { 30, 0.1 },
+	}
+
+	for i := range tests {
+		x := randomPoints(tests[i].n, L, int64(i))
+		groups, _ := FOF(L, x, nil, tests[i].r, 10, 1)
+		cellGroups, _ := CellFOF(L, x, nil, tests[i].r, 1)
+		if !samePartition(groups, cellGroups) {
+			t.Errorf("%d) CellFOF and FOF found different groups.", i)
+		}
+	}
+}
+
+func TestCellFOFExactR(t *testing.T) {
+	// Pairs which are exactly r apart before rounding must be linked the
+	// same way FOF links them.
+	L, r := float32(100), float32(0.7)
+	x := randomPoints(2000, L - 1, 0)
+	for i := range x {
+		x = append(x, [3]float32{ x[i][0] + r, x[i][1], x[i][2] })
+	}
+
+	groups, _ := FOF(L, x, nil, r, 10, 2)
+	cellGroups, _ := CellFOF(L, x, nil, r, 2)
+	if !samePartition(groups, cellGroups) {
+		t.Errorf("CellFOF and FOF found different groups for pairs at r.")
+	}
+	for _, workers := range []int{ 1, 4 } {
+		pGroups, _ := ParallelFOF(L, x, nil, r, 2, workers)
+		if !samePartition(groups, pGroups) {
+			t.Errorf("ParallelFOF with %d workers and FOF found different " +
+				"groups for pairs at r.", workers)
+		}
+	}
+}
+
+func TestCellFOFEmpty(t *testing.T) {
+	groups, cenGroups := CellFOF(10, nil, [][3]float32{ {1, 2, 3} }, 1, 1)
+	if len(groups) != 0 || !Int32Eq(cenGroups, []int32{ -1 }) {
+		t.Errorf("Expected no groups and cenGroups [-1], got %v and %v.",
+			groups, cenGroups)
+	}
+
+	groups, _ = CellFOFBox(OpenBox([][3]float32{ }), nil, nil, 1, 1)
+	if len(groups) != 0 {
+		t.Errorf("Expected no groups in an empty OpenBox, got %v.", groups)
+	}
+}
@@ -4,44 +4,60 @@ const (
 	listEnd = -1
 )
 
-// Compact list is a compact data structure for storing many small lists.
+// Index is the set of types which can be used to index points. int32 is used
+// everywhere by default and halves the memory of index arrays. int64 is
+// needed for runs with more than about 2.1 billion points.
+type Index interface {
+	~int32 | ~int64
+}
+
+// CompactListOf is a compact data structure for storing many small lists.
 // Avoids both excessive malloc overhead and heap fragmentation.
-type CompactList struct {
-	start, next, data []int32
+type CompactListOf[I Index] struct {
+	start, next, data []I
 }
 
+// CompactList is a CompactListOf with int32 indices.
+type CompactList = CompactListOf[int32]
+
 // NewCompactList creates a new CompactList associated with n objects that have
 // IDs ranging across [0, n).
 func NewCompactList(n int32) *CompactList {
-	l := &CompactList{ make([]int32, n), nil, nil, }
+	return NewCompactListOf(n)
+}
+
+// NewCompactListOf creates a new CompactListOf associated with n objects that
+// have IDs ranging across [0, n).
+func NewCompactListOf[I Index](n I) *CompactListOf[I] {
+	l := &CompactListOf[I]{ make([]I, n), nil, nil, }
 	for i := range l.start { l.start[i] = listEnd }
 	return l
 }
 
 // Push adds a piece of data to the object with the given ID.
-func (l *CompactList) Push(id, data int32) {
+func (l *CompactListOf[I]) Push(id, data I) {
 	if l.start[id] == listEnd {
-		n := int32(len(l.next))
+		n := I(len(l.next))
 		l.start[id] = n
 		l.next = append(l.next, listEnd)
 		l.data = append(l.data, data)
 	} else {	
 		i := l.start[id]
-		l.start[id] = int32(len(l.next))
+		l.start[id] = I(len(l.next))
 		l.next = append(l.next, i)
 		l.data = append(l.data, data)
 	}
 }
 
 // Head returns the first data item in a list.
-func (l *CompactList) Head(id int32) (int32, bool) {
+func (l *CompactListOf[I]) Head(id I) (I, bool) {
 	if l.start[id] == listEnd { return listEnd, false }
 	return l.data[l.start[id]], true
 }
 
 // GetArray returns all the elements associated with the given ID in order
 // as an array.
-func (l *CompactList) GetArray(id int32, buf []int32) []int32 {
+func (l *CompactListOf[I]) GetArray(id I, buf []I) []I {
 	buf = buf[:0]
 	if l.start[id] == listEnd { return buf }
 	
@@ -75,3 +75,18 @@ func Int32Eq(x, y []int32) bool {
 	}
 	return true
 }
+
+func TestCompactListOfInt64(t *testing.T) {
+	l := NewCompactListOf(int64(3))
+	l.Push(2, 1 << 40)
+	l.Push(2, 7)
+
+	buf := l.GetArray(2, nil)
+	if len(buf) != 2 || buf[0] != 7 || buf[1] != 1 << 40 {
+		t.Errorf("Expected GetArray(2) = %d, got %d.",
+			[]int64{ 7, 1 << 40 }, buf)
+	}
+	if _, ok := l.Head(0); ok {
+		t.Errorf("Expected list 0 to be empty.")
+	}
+}
@@ -0,0 +1,100 @@
+package symfof
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrentUnionFinder is a union-find structure which many goroutines can
+// call Union and Find on at the same time. Roots are linked by index with a
+// compare-and-swap so that the larger root always points to the smaller one,
+// which means that no cycles can form, and Find uses path halving.
+//
+// Sizes are not tracked while linking. Call Flatten once all the goroutines
+// are done to get the same view of the groups as a UnionFinder.
+type ConcurrentUnionFinder struct {
+	Parent []int32
+}
+
+func NewConcurrentUnionFinder(n int32) *ConcurrentUnionFinder {
+	uf := &ConcurrentUnionFinder{ Parent: make([]int32, n) }
+	for i := range uf.Parent {
+		uf.Parent[i] = int32(i)
+	}
+	return uf
+}
+
+func (uf *ConcurrentUnionFinder) Find(i int32) int32 {
+	for {
+		p := atomic.LoadInt32(&uf.Parent[i])
+		if p == i { return i }
+		gp := atomic.LoadInt32(&uf.Parent[p])
+		if gp != p {
+			// Losing this race is fine: someone else already shortened
+			// the path.
+			atomic.CompareAndSwapInt32(&uf.Parent[i], p, gp)
+		}
+		i = gp
+	}
+}
+
+func (uf *ConcurrentUnionFinder) Union(i, j int32) {
+	for {
+		rooti, rootj := uf.Find(i), uf.Find(j)
+		if rooti == rootj { return }
+		if rooti < rootj { rooti, rootj = rootj, rooti }
+		// If rooti stopped being a root while we were looking, try again.
+		if atomic.CompareAndSwapInt32(&uf.Parent[rooti], rooti, rootj) {
+			return
+		}
+	}
+}
+
+// Flatten returns a UnionFinder with the same groups as uf, where every
+// element points directly to its root. It must not be called while other
+// goroutines are still calling Union.
+func (uf *ConcurrentUnionFinder) Flatten() *UnionFinder {
+	n := int32(len(uf.Parent))
+	out := &UnionFinder{
+		Parent: make([]int32, n),
+		Size: make([]int32, n),
+	}
+
+	// Parents always have smaller indices than their children, so every
+	// parent is already flattened by the time its children are reached.
+	for i := int32(0); i < n; i++ {
+		p := uf.Parent[i]
+		if p == i {
+			out.Parent[i] = i
+			out.NGroup++
+		} else {
+			out.Parent[i] = out.Parent[p]
+		}
+		out.Size[out.Parent[i]]++
+	}
+
+	return out
+}
+
+// ConcurrentLink unions every pair of points closer than the linking length
+// into uf using the given number of goroutines.
+func (cl *CellLinker) ConcurrentLink(uf *ConcurrentUnionFinder, workers int) {
+	if workers < 1 { workers = 1 }
+	if int64(workers) > cl.Cells[2] { workers = int(cl.Cells[2]) }
+
+	wg := &sync.WaitGroup{ }
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			z0 := int64(w)*cl.Cells[2]/int64(workers)
+			z1 := int64(w + 1)*cl.Cells[2]/int64(workers)
+
+			cp := &cellPairer{ }
+			cl.linkCells(z0, z1, cp, func(i, j int32, local bool) {
+				uf.Union(i, j)
+			})
+		}(w)
+	}
+	wg.Wait()
+}
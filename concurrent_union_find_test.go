@@ -0,0 +1,70 @@
+package symfof
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentUnionFinder(t *testing.T) {
+	n, nEdges, workers := int32(10000), 8000, 8
+	rng := rand.New(rand.NewSource(3))
+	ei, ej := make([]int32, nEdges), make([]int32, nEdges)
+	for k := range ei {
+		ei[k], ej[k] = rng.Int31n(n), rng.Int31n(n)
+	}
+
+	uf := NewUnionFinder(n)
+	for k := range ei { uf.Union(ei[k], ej[k]) }
+
+	cuf := NewConcurrentUnionFinder(n)
+	wg := &sync.WaitGroup{ }
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for k := w; k < nEdges; k += workers {
+				cuf.Union(ei[k], ej[k])
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	flat := cuf.Flatten()
+	if flat.NGroup != uf.NGroup {
+		t.Errorf("Expected %d groups, got %d.", uf.NGroup, flat.NGroup)
+	}
+
+	groups, flatGroups := make([]int32, n), make([]int32, n)
+	for i := int32(0); i < n; i++ {
+		groups[i], flatGroups[i] = uf.Find(i), flat.Parent[i]
+		if flat.Parent[flat.Parent[i]] != flat.Parent[i] {
+			t.Errorf("Particle %d was not flattened.", i)
+		}
+		if flat.Size[flatGroups[i]] != uf.Size[groups[i]] {
+			t.Errorf("Particle %d is in a group of size %d, expected %d.",
+				i, flat.Size[flatGroups[i]], uf.Size[groups[i]])
+		}
+	}
+	if !samePartition(groups, flatGroups) {
+		t.Errorf("ConcurrentUnionFinder found different groups.")
+	}
+}
+
+func TestConcurrentLink(t *testing.T) {
+	L, r := float32(40), float32(1.2)
+	x := randomPoints(5000, L, 12)
+
+	cl := NewCellLinker(L, x, r)
+	uf := NewUnionFinder(int32(len(x)))
+	cl.Link(uf)
+	groups := groupLabels(uf, 1)
+
+	cuf := NewConcurrentUnionFinder(int32(len(x)))
+	cl.ConcurrentLink(cuf, 6)
+	cGroups := groupLabels(cuf.Flatten(), 1)
+
+	if !samePartition(groups, cGroups) {
+		t.Errorf("ConcurrentLink and Link found different groups.")
+	}
+}
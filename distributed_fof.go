@@ -0,0 +1,244 @@
+package symfof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// DistributedFOF runs FOF across several cooperating processes connected by
+// t. The box is split into t.Size() slabs of equal width along the x-axis
+// and each process passes the particles in the slab matching its rank: x
+// gives their positions and idx gives their global indices.
+//
+// Processes send the particles near their faces to their neighbours as
+// ghosts, link their own slab, and then send the union-find over their
+// boundary particles to rank 0, which merges them and sends back the final
+// labels. As with SlabFOF, a group's label is the smallest global index of
+// its members, and labels are returned in the same order as x.
+//
+// Ghosts only come from the two neighbouring slabs, so an error is returned
+// if the slabs are narrower than r.
+func DistributedFOF(
+	t Transport, L float32, x [][3]float32, idx []int64,
+	r float32, nGrid, nMin int,
+) ([]int64, error) {
+	n, rank := t.Size(), t.Rank()
+	w := L/float32(n)
+	if n > 1 && (w < r || w + 3*r >= L) {
+		return nil, fmt.Errorf("DistributedFOF cannot use a linking " +
+			"length of %g with %d processes in a box with width %g.", r, n, L)
+	}
+
+	src := &ghostSlabSource{ x: x, idx: idx, n: n }
+	if err := src.exchange(t, L, r); err != nil { return nil, err }
+
+	sl := &slabLinker{ L: L, src: src, r: r, w: w, nGrid: nGrid }
+	if err := sl.link(rank); err != nil { return nil, err }
+	b := &slabBoundary{ index: map[int64]int32{ } }
+	b.addSlab(sl)
+	b.uf = NewUnionFinder(int32(len(b.keys)))
+	for k := range b.edgeI { b.uf.Union(b.edgeI[k], b.edgeJ[k]) }
+
+	nodeLabel, nodeSize, err := mergeBoundaries(t, b)
+	if err != nil { return nil, err }
+
+	labels := make([]int64, len(x))
+	for j := range labels {
+		root := sl.uf.Find(int32(j))
+		labels[j] = sl.label[root]
+		size := int64(sl.owned[root])
+		if node, ok := b.index[labels[j]]; ok {
+			labels[j], size = nodeLabel[node], nodeSize[node]
+		}
+		if size < int64(nMin) { labels[j] = -1 }
+	}
+
+	return labels, nil
+}
+
+// ghostSlabSource is a SlabSource for the slab owned by a single process
+// along with the ghosts it received from its neighbours.
+type ghostSlabSource struct {
+	x        [][3]float32
+	idx      []int64
+	n        int
+	ghostX   [][3]float32
+	ghostIdx []int64
+}
+
+func (src *ghostSlabSource) Slabs() int { return src.n }
+
+func (src *ghostSlabSource) ReadSlab(
+	i int, ghost float32, x [][3]float32, idx []int64,
+) ([][3]float32, []int64, int, error) {
+	x = append(append(x[:0], src.x...), src.ghostX...)
+	idx = append(append(idx[:0], src.idx...), src.ghostIdx...)
+	return x, idx, len(src.x), nil
+}
+
+// exchange sends the particles within r of each face to the neighbour on
+// the other side and receives the neighbours' particles as ghosts.
+func (src *ghostSlabSource) exchange(t Transport, L, r float32) error {
+	n, rank := t.Size(), t.Rank()
+	if n == 1 { return nil }
+
+	w := L/float32(n)
+	lo, hi := float32(rank)*w, float32(rank + 1)*w
+	left, right := (rank + n - 1) % n, (rank + 1) % n
+	neighbours := []int{ left }
+	if right != left { neighbours = append(neighbours, right) }
+
+	// Sending a little extra keeps rounding from losing ghosts.
+	tol := r*1e-3
+	for _, nb := range neighbours {
+		x, idx := [][3]float32{ }, []int64{ }
+		for j := range src.x {
+			if (nb == left && src.x[j][0] - lo <= r + tol) ||
+				(nb == right && hi - src.x[j][0] <= r + tol) {
+				x, idx = append(x, src.x[j]), append(idx, src.idx[j])
+			}
+		}
+
+		msg, err := encodeMessage(int64(len(x)), x, idx)
+		if err != nil { return err }
+		if err := t.Send(nb, msg); err != nil { return err }
+	}
+
+	for _, nb := range neighbours {
+		msg, err := t.Recv(nb)
+		if err != nil { return err }
+		buf := bytes.NewReader(msg)
+		// Each ghost is a position and a global index.
+		nGhost, err := readCount(buf, 3*4 + 8)
+		if err != nil { return err }
+		x, idx := make([][3]float32, nGhost), make([]int64, nGhost)
+		if err := decodeMessage(buf, x, idx); err != nil { return err }
+		src.ghostX = append(src.ghostX, x...)
+		src.ghostIdx = append(src.ghostIdx, idx...)
+	}
+
+	return nil
+}
+
+// mergeBoundaries sends every process's boundary union-find to rank 0, which
+// merges them. It returns the final label and size of each of b's nodes.
+func mergeBoundaries(
+	t Transport, b *slabBoundary,
+) (label, size []int64, err error) {
+	if t.Rank() != 0 {
+		ufData, err := b.uf.MarshalBinary()
+		if err != nil { return nil, nil, err }
+		msg, err := encodeMessage(int64(len(b.keys)), b.keys, b.count,
+			int64(len(ufData)), ufData)
+		if err != nil { return nil, nil, err }
+		if err := t.Send(0, msg); err != nil { return nil, nil, err }
+
+		reply, err := t.Recv(0)
+		if err != nil { return nil, nil, err }
+		label, size = make([]int64, len(b.keys)), make([]int64, len(b.keys))
+		err = decodeMessage(bytes.NewReader(reply), label, size)
+		return label, size, err
+	}
+
+	boundaries := []*slabBoundary{ b }
+	for rank := 1; rank < t.Size(); rank++ {
+		msg, err := t.Recv(rank)
+		if err != nil { return nil, nil, err }
+		buf := bytes.NewReader(msg)
+
+		// Each key has a count.
+		nKeys, err := readCount(buf, 8 + 8)
+		if err != nil { return nil, nil, err }
+		rb := &slabBoundary{
+			keys: make([]int64, nKeys), count: make([]int64, nKeys),
+			uf: &UnionFinder{ },
+		}
+		if err := decodeMessage(buf, rb.keys, rb.count); err != nil {
+			return nil, nil, err
+		}
+		nUF, err := readCount(buf, 1)
+		if err != nil { return nil, nil, err }
+		ufData := make([]byte, nUF)
+		if err := decodeMessage(buf, ufData); err != nil {
+			return nil, nil, err
+		}
+		if err := rb.uf.UnmarshalBinary(ufData); err != nil {
+			return nil, nil, err
+		}
+		if int64(len(rb.uf.Parent)) != nKeys {
+			return nil, nil, fmt.Errorf("Rank %d sent %d boundary keys but " +
+				"a union-find over %d elements.", rank, nKeys,
+				len(rb.uf.Parent))
+		}
+		boundaries = append(boundaries, rb)
+	}
+
+	// Give every key a node in the combined boundary and then merge each
+	// process's union-find into it.
+	all := &slabBoundary{ index: map[int64]int32{ } }
+	remaps := make([][]int32, len(boundaries))
+	for i, rb := range boundaries {
+		remaps[i] = make([]int32, len(rb.keys))
+		for k, key := range rb.keys {
+			node := all.node(key)
+			remaps[i][k] = node
+			all.count[node] += rb.count[k]
+		}
+	}
+	all.uf = NewUnionFinder(int32(len(all.keys)))
+	for i, rb := range boundaries { all.uf.Merge(rb.uf, remaps[i]) }
+	all.resolve()
+
+	for i := len(boundaries) - 1; i >= 0; i-- {
+		label = make([]int64, len(remaps[i]))
+		size = make([]int64, len(remaps[i]))
+		for k, node := range remaps[i] {
+			root := all.uf.Find(node)
+			label[k], size[k] = all.label[root], all.size[root]
+		}
+		if i == 0 { break }
+
+		msg, err := encodeMessage(label, size)
+		if err != nil { return nil, nil, err }
+		if err := t.Send(i, msg); err != nil { return nil, nil, err }
+	}
+
+	return label, size, nil
+}
+
+// encodeMessage writes each piece of data to a little-endian byte array.
+func encodeMessage(data ...any) ([]byte, error) {
+	buf := &bytes.Buffer{ }
+	for _, d := range data {
+		if err := binary.Write(buf, binary.LittleEndian, d); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// readCount reads the number of elements in the next part of a message and
+// returns an error if it is negative or if buf is too short to hold that
+// many elements of elemSize bytes.
+func readCount(buf *bytes.Reader, elemSize int64) (int64, error) {
+	var n int64
+	if err := binary.Read(buf, binary.LittleEndian, &n); err != nil {
+		return 0, err
+	}
+	if n < 0 || n > int64(buf.Len())/elemSize {
+		return 0, fmt.Errorf("A message with %d bytes left cannot hold %d " +
+			"elements of %d bytes.", buf.Len(), n, elemSize)
+	}
+	return n, nil
+}
+
+// decodeMessage reads each piece of data from a little-endian byte stream.
+func decodeMessage(buf *bytes.Reader, data ...any) error {
+	for _, d := range data {
+		if err := binary.Read(buf, binary.LittleEndian, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
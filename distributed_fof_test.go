@@ -0,0 +1,197 @@
+package symfof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// startTransports connects n SocketTransports running in the same process.
+func startTransports(
+	t *testing.T, network string, n int,
+) []*SocketTransport {
+	lns, addrs := make([]net.Listener, n), make([]string, n)
+	for i := range lns {
+		addr := "127.0.0.1:0"
+		if network == "unix" {
+			addr = filepath.Join(t.TempDir(), fmt.Sprintf("%d.sock", i))
+		}
+		ln, err := net.Listen(network, addr)
+		if err != nil { t.Fatalf("Could not listen: %s", err.Error()) }
+		lns[i], addrs[i] = ln, ln.Addr().String()
+	}
+
+	ts, errs := make([]*SocketTransport, n), make([]error, n)
+	wg := &sync.WaitGroup{ }
+	for i := range ts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ts[i], errs[i] = NewSocketTransport(lns[i], network, addrs, i)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range errs {
+		if errs[i] != nil {
+			t.Fatalf("Rank %d could not connect: %s", i, errs[i].Error())
+		}
+	}
+	return ts
+}
+
+func TestSocketTransport(t *testing.T) {
+	for _, network := range []string{ "tcp", "unix" } {
+		n, nMsg := 4, 5
+		ts := startTransports(t, network, n)
+
+		for i := range ts {
+			for j := range ts {
+				if i == j { continue }
+				for k := 0; k < nMsg; k++ {
+					msg := []byte(fmt.Sprintf("%d->%d #%d", i, j, k))
+					if err := ts[i].Send(j, msg); err != nil {
+						t.Fatalf("%s: Send failed: %s", network, err.Error())
+					}
+				}
+			}
+		}
+
+		for j := range ts {
+			for i := range ts {
+				if i == j { continue }
+				for k := 0; k < nMsg; k++ {
+					msg, err := ts[j].Recv(i)
+					exp := fmt.Sprintf("%d->%d #%d", i, j, k)
+					if err != nil || string(msg) != exp {
+						t.Errorf("%s: expected message '%s', got '%s' (%v).",
+							network, exp, msg, err)
+					}
+				}
+			}
+		}
+
+		for i := range ts { ts[i].Close() }
+	}
+}
+
+func TestSocketTransportCorruptLength(t *testing.T) {
+	ts := startTransports(t, "tcp", 2)
+	defer func() { for i := range ts { ts[i].Close() } }()
+
+	// A length prefix far beyond the limit must be an error rather than an
+	// allocation.
+	conn := ts[1].conns[0]
+	err := binary.Write(conn, binary.LittleEndian, uint64(1 << 62))
+	if err != nil { t.Fatalf("Could not write: %s", err.Error()) }
+	if msg, err := ts[0].Recv(1); err == nil {
+		t.Errorf("Expected an error from a corrupt length, got %d bytes.",
+			len(msg))
+	}
+}
+
+func TestReadCount(t *testing.T) {
+	tests := []struct{
+		n int64
+		payload int
+		ok bool
+	} {
+		{ 0, 0, true }, { 3, 24, true }, { 3, 23, false },
+		{ -1, 8, false }, { 1 << 60, 8, false },
+	}
+
+	for i := range tests {
+		buf := &bytes.Buffer{ }
+		binary.Write(buf, binary.LittleEndian, tests[i].n)
+		buf.Write(make([]byte, tests[i].payload))
+		n, err := readCount(bytes.NewReader(buf.Bytes()), 8)
+		if tests[i].ok && (err != nil || n != tests[i].n) {
+			t.Errorf("%d) expected count %d, got %d (%v).",
+				i, tests[i].n, n, err)
+		} else if !tests[i].ok && err == nil {
+			t.Errorf("%d) expected an error for a count of %d with %d " +
+				"bytes left.", i, tests[i].n, tests[i].payload)
+		}
+	}
+}
+
+func TestDistributedFOF(t *testing.T) {
+	L, r := float32(40), float32(1.6)
+	x := randomPoints(5000, L, 14)
+	groups, _ := CellFOF(L, x, nil, r, 3)
+
+	minIdx := map[int32]int64{ }
+	for i := len(groups) - 1; i >= 0; i-- {
+		if groups[i] != -1 { minIdx[groups[i]] = int64(i) }
+	}
+
+	for _, network := range []string{ "tcp", "unix" } {
+		for _, n := range []int{ 1, 2, 4 } {
+			ts := startTransports(t, network, n)
+
+			// Split the particles into slabs.
+			w := L/float32(n)
+			xs, idxs := make([][][3]float32, n), make([][]int64, n)
+			for i := range x {
+				rank := int(x[i][0]/w)
+				if rank >= n { rank = n - 1 }
+				xs[rank] = append(xs[rank], x[i])
+				idxs[rank] = append(idxs[rank], int64(i))
+			}
+
+			labels, errs := make([][]int64, n), make([]error, n)
+			wg := &sync.WaitGroup{ }
+			for rank := range ts {
+				wg.Add(1)
+				go func(rank int) {
+					defer wg.Done()
+					labels[rank], errs[rank] = DistributedFOF(
+						ts[rank], L, xs[rank], idxs[rank], r, 20, 3)
+				}(rank)
+			}
+			wg.Wait()
+			for i := range ts { ts[i].Close() }
+
+			distGroups := make([]int32, len(x))
+			for rank := range labels {
+				if errs[rank] != nil {
+					t.Fatalf("%s, %d ranks: rank %d failed: %s",
+						network, n, rank, errs[rank].Error())
+				}
+				for k, i := range idxs[rank] {
+					distGroups[i] = int32(labels[rank][k])
+					if groups[i] != -1 && labels[rank][k] != minIdx[groups[i]] {
+						t.Errorf("%s, %d ranks: particle %d has label %d, " +
+							"expected %d.", network, n, i, labels[rank][k],
+							minIdx[groups[i]])
+					}
+				}
+			}
+			if !samePartition(groups, distGroups) {
+				t.Errorf("%s, %d ranks: DistributedFOF and CellFOF found " +
+					"different groups.", network, n)
+			}
+		}
+	}
+}
+
+func TestDistributedFOFNarrowSlabs(t *testing.T) {
+	// Slabs which are narrower than the linking length would need ghosts
+	// from more than their immediate neighbours.
+	L, r, n := float32(40), float32(6), 8
+	ts := startTransports(t, "tcp", n)
+	defer func() { for i := range ts { ts[i].Close() } }()
+
+	x := randomPoints(100, L, 15)
+	for rank := range ts {
+		_, err := DistributedFOF(ts[rank], L, x, nil, r, 20, 3)
+		if err == nil {
+			t.Errorf("Rank %d: expected an error for slabs of width %g " +
+				"with r = %g.", rank, L/float32(n), r)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package symfof
+
+import (
+	"fmt"
+	"sync"
+)
+
+// FindMany finds the points within radii[i] of centers[i] for every center,
+// using the given number of goroutines, each with its own cursor. If radii
+// has a single element, it is used for every center. In the returned list,
+// the points found around center i are stored under the ID i. Radii are
+// checked before any goroutines start, so FindMany panics on the calling
+// goroutine if any radius is too large for the box.
+func (f *FinderOf[F, I]) FindMany(
+	centers [][3]F, radii []F, workers int,
+) *CompactListOf[I] {
+	if len(radii) != 1 && len(radii) != len(centers) {
+		panic("FindMany needs either one radius or one radius per center.")
+	}
+	maxR := f.g.Box.MaxRadius()
+	for i, r := range radii {
+		if !(r < maxR) {
+			panic(fmt.Sprintf("FindMany cannot do searches with radius " +
+				"radii[%d] = %g in a box with width %g.", i, r, 2*maxR))
+		}
+	}
+	if workers < 1 { workers = 1 }
+	if workers > len(centers) { workers = len(centers) }
+
+	// Each worker handles a contiguous range of centers and stores its
+	// results in center order, so they can be concatenated afterwards.
+	data := make([][]I, workers)
+	counts := make([]int, len(centers))
+
+	wg := &sync.WaitGroup{ }
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			c := f.NewCursor()
+			i0, i1 := w*len(centers)/workers, (w + 1)*len(centers)/workers
+			for i := i0; i < i1; i++ {
+				r := radii[0]
+				if len(radii) > 1 { r = radii[i] }
+
+				idx := c.Find(centers[i], r)
+				data[w] = append(data[w], idx...)
+				counts[i] = len(idx)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	l := &CompactListOf[I]{ start: make([]I, len(centers)) }
+	for w := range data { l.data = append(l.data, data[w]...) }
+
+	// Link together each center's run of points.
+	l.next = make([]I, len(l.data))
+	n := 0
+	for i, count := range counts {
+		if count == 0 {
+			l.start[i] = listEnd
+			continue
+		}
+
+		l.start[i] = I(n)
+		for j := n; j < n + count - 1; j++ { l.next[j] = I(j + 1) }
+		l.next[n + count - 1] = listEnd
+		n += count
+	}
+
+	return l
+}
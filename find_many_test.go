@@ -0,0 +1,114 @@
+package symfof
+
+import (
+	"testing"
+)
+
+func TestFindMany(t *testing.T) {
+	L := float32(100)
+	x := randomPoints(5000, L, 47)
+	centers := randomPoints(300, L, 48)
+	radii := make([]float32, len(centers))
+	for i := range radii { radii[i] = 1 + float32(i % 7) }
+
+	f := NewFinder(L, x, 25)
+	for _, workers := range []int{ 1, 3, 8, 1000 } {
+		l := f.FindMany(centers, radii, workers)
+		buf := []int32{ }
+		for i := range centers {
+			buf = l.GetArray(int32(i), buf)
+			if exp := f.Find(centers[i], radii[i]); !sameIndices(buf, exp) {
+				t.Errorf("workers = %d: expected center %d to have %d " +
+					"points, got %d.", workers, i, len(exp), len(buf))
+			}
+		}
+	}
+
+	l := f.FindMany(centers, []float32{ 5 }, 4)
+	buf := []int32{ }
+	for i := range centers {
+		buf = l.GetArray(int32(i), buf)
+		if exp := f.Find(centers[i], 5); !sameIndices(buf, exp) {
+			t.Errorf("shared radius: expected center %d to have %d " +
+				"points, got %d.", i, len(exp), len(buf))
+		}
+	}
+}
+
+func TestFindManyLargeRadius(t *testing.T) {
+	L := float32(100)
+	f := NewFinder(L, randomPoints(100, L, 50), 10)
+	centers := randomPoints(10, L, 51)
+	radii := make([]float32, len(centers))
+	for i := range radii { radii[i] = 1 }
+	radii[7] = 50
+
+	// The panic has to happen on this goroutine to be recovered here.
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected FindMany to panic on a radius of %g.", radii[7])
+		}
+	}()
+	f.FindMany(centers, radii, 4)
+}
+
+func TestFinderCursors(t *testing.T) {
+	L := float32(100)
+	x := randomPoints(5000, L, 49)
+	centers := randomPoints(200, L, 50)
+
+	f := NewFinder(L, x, 25)
+	exp := make([][]int32, len(centers))
+	for i := range centers {
+		exp[i] = append([]int32{ }, f.Find(centers[i], 4)...)
+	}
+
+	errs := make(chan int, len(centers)*4)
+	done := make(chan struct{ })
+	for w := 0; w < 4; w++ {
+		go func() {
+			c := f.NewCursor()
+			for i := range centers {
+				if !Int32Eq(c.Find(centers[i], 4), exp[i]) { errs <- i }
+			}
+			done <- struct{ }{ }
+		}()
+	}
+	for w := 0; w < 4; w++ { <-done }
+	close(errs)
+
+	for i := range errs {
+		t.Errorf("Concurrent cursor gave the wrong points for center %d.", i)
+	}
+
+	// Cursors made before an Append see the new points, but the results of
+	// their earlier queries aren't touched.
+	inc := NewEmptyFinder(CubicBox(L), 25)
+	c := inc.NewCursor()
+	inc.Append(x[:2500])
+	before := c.Find(centers[0], 4)
+	nBefore := len(before)
+	old := append([]int32{ }, before...)
+
+	inc.Append(x[2500:])
+	if !Int32Eq(before, old) {
+		t.Errorf("Append changed the results of an earlier query.")
+	}
+	if idx := c.Find(centers[0], 4); !sameIndices(idx, exp[0]) {
+		t.Errorf("Expected old cursor to find %d points after Append, " +
+			"got %d.", len(exp[0]), len(idx))
+	} else if nBefore >= len(idx) {
+		t.Errorf("Expected fewer points before the second Append, got %d.",
+			nBefore)
+	}
+
+	// The same is true for Reuse.
+	c = f.NewCursor()
+	f.Reuse(centers)
+	reused := f.Find(centers[0], 4)
+	if idx := c.Find(centers[0], 4); len(idx) == 0 ||
+		!sameIndices(idx, reused) {
+		t.Errorf("Expected old cursor to find %v after Reuse, got %v.",
+			reused, idx)
+	}
+}
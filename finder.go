@@ -2,144 +2,334 @@ package symfof
 
 import (
 	"fmt"
+	"math"
+	"sort"
 )
 
-// Finder finds the halos in group A that are within the radii of
+// FinderOf finds the halos in group A that are within the radii of
 // group B. It is optimized for the case where A is extremely large and search
 // radii are also very large.
 //
 // To do this, it does not allow for host list identifications and does not
 // memoize results.
-type Finder struct {
-	g      *Grid
-	gBuf   []int32
-	idxBuf []int32
-	dr2Buf []float32
-	x      [][3]float32
-	bufi   int
-	cells  int
+//
+// Queries are run through the FinderCursorOf embedded in each FinderOf,
+// which owns the query buffers. To query a FinderOf from several goroutines,
+// give each goroutine its own cursor with NewCursor.
+type FinderOf[F Float, I Index] struct {
+	FinderCursorOf[F, I]
 }
 
-func getBounds(x [][3]float32, L float32, cells int) (*Bounds, int) {
-	fb := PointBoundsNonPeriodic(x)
-	maxSpan := fb.Span[0]
-	if maxSpan < fb.Span[1] { maxSpan = fb.Span[1] }
-	if maxSpan < fb.Span[2] { maxSpan = fb.Span[2] }
+// FinderCursorOf runs queries against the points in a FinderOf. Cursors
+// share the FinderOf's grid but have their own buffers, so different cursors
+// can be used by different goroutines at the same time.
+//
+// Append and Reuse update the shared grid in place rather than making a new
+// one. No cursor may be used while either is running, but once they return,
+// every cursor, including ones made earlier, queries the updated points.
+// Arrays returned by a cursor's earlier queries are left alone until that
+// cursor's next query.
+type FinderCursorOf[F Float, I Index] struct {
+	*finderIndex[F, I]
+	gBuf    []I
+	idxBuf  []I
+	dr2Buf  []F
+	sepBuf  [][3]F
+	cellBuf []int
+	bufi    int
+}
 
-	//cw := maxSpan / defaultFinderCells
-	//cells := int(L / cw)
-	
-	return FloatBoundsToIntBounds(fb, L/float32(cells)), cells
+// FinderCursor is a FinderCursorOf for float32 positions and int32 indices.
+type FinderCursor = FinderCursorOf[float32, int32]
+
+// finderIndex holds the data shared by a FinderOf's cursors. It is only
+// modified in place by Append and Reuse.
+type finderIndex[F Float, I Index] struct {
+	g     *GridOf[F, I]
+	x     [][3]F
+	cells [3]int
+	// maxLength is the number of points in the fullest grid cell.
+	maxLength int
+	// ownsX is true if x was allocated by Append and can be appended to
+	// without overwriting the caller's data.
+	ownsX bool
+}
+
+// Finder is a FinderOf for float32 positions and int32 indices.
+type Finder = FinderOf[float32, int32]
+
+func getBounds[F Float](
+	x [][3]F, box *BoxOf[F], cells [3]int,
+) (*Bounds, [3]int) {
+	fb := PointBoundsNonPeriodic(x)
+	return box.CellBounds(fb, cells), cells
 }
 
 // NewFinder creates a new Finder corresponding to the given
 // Grid. The Grid contains halos from group A.
-func NewFinder(L float32, x [][3]float32, cells int) *Finder {
-	b, cells  := getBounds(x, L, cells)
-	g := NewGrid(b, cells, L, len(x))
+func NewFinder[F Float](L F, x [][3]F, cells int) *FinderOf[F, int32] {
+	return NewFinderBox(CubicBox(L), x, cells)
+}
+
+// NewFinderBox creates a new Finder for points in the given box. cells is
+// the number of grid cells along the widest axis of the box.
+func NewFinderBox[F Float](
+	box *BoxOf[F], x [][3]F, cells int,
+) *FinderOf[F, int32] {
+	return NewFinderOf[F, int32](box, x, cells)
+}
+
+// NewFinderOf creates a new FinderOf for points in the given box which
+// returns indices of type I.
+func NewFinderOf[F Float, I Index](
+	box *BoxOf[F], x [][3]F, cells int,
+) *FinderOf[F, I] {
+	b, boxCells := getBounds(x, box, box.Cells(cells))
+	g := NewGridOf[F, I](b, box, boxCells, len(x))
 	g.Insert(x)
 	
-	f := &Finder{
-		g: g,
-		gBuf: make([]int32, g.MaxLength()),
-		idxBuf: []int32{ },
-		x: x, cells: cells,
+	idx := &finderIndex[F, I]{
+		g: g, x: x, cells: boxCells, maxLength: g.MaxLength(),
+	}
+
+	return &FinderOf[F, I]{ *idx.newCursor() }
+}
+
+// NewEmptyFinder creates a Finder with no points whose grid covers the
+// entire box, so that points anywhere in the box can be added with Append.
+// cells is the number of grid cells along the widest axis of the box.
+func NewEmptyFinder[F Float](box *BoxOf[F], cells int) *FinderOf[F, int32] {
+	return NewEmptyFinderOf[F, int32](box, cells)
+}
+
+// NewEmptyFinderOf creates a FinderOf with no points whose grid covers the
+// entire box and which returns indices of type I.
+func NewEmptyFinderOf[F Float, I Index](
+	box *BoxOf[F], cells int,
+) *FinderOf[F, I] {
+	boxCells := box.Cells(cells)
+	b := &Bounds{ Span: boxCells }
+	g := NewGridOf[F, I](b, box, boxCells, 0)
+
+	idx := &finderIndex[F, I]{
+		g: g, x: [][3]F{ }, cells: boxCells, ownsX: true,
+	}
+
+	return &FinderOf[F, I]{ *idx.newCursor() }
+}
+
+// NewCursor creates a new cursor for querying f. Each goroutine which
+// queries f needs its own cursor.
+func (f *FinderOf[F, I]) NewCursor() *FinderCursorOf[F, I] {
+	return f.finderIndex.newCursor()
+}
+
+func (idx *finderIndex[F, I]) newCursor() *FinderCursorOf[F, I] {
+	return &FinderCursorOf[F, I]{
+		finderIndex: idx,
+		gBuf: make([]I, idx.maxLength),
+		idxBuf: []I{ },
 	}
+}
 
-	return f
+// readCell returns the indices of the points in grid cell idx. The returned
+// array is an internal buffer.
+func (sf *FinderCursorOf[F, I]) readCell(idx int) []I {
+	// The grid may have grown since the cursor was made.
+	if cap(sf.gBuf) < sf.maxLength { sf.gBuf = make([]I, sf.maxLength) }
+	sf.gBuf = sf.g.ReadIndices(idx, sf.gBuf)
+	return sf.gBuf
+}
+
+// Append adds the points x to f without rebuilding its grid. The new points
+// have indices following the existing ones. Every point must be within the
+// bounds of f's grid, which is always true for Finders made by
+// NewEmptyFinder. f keeps its own copy of the positions. See FinderCursorOf
+// for how this affects existing cursors.
+func (f *FinderOf[F, I]) Append(x [][3]F) {
+	f.g.Append(x)
+	if !f.ownsX {
+		f.x, f.ownsX = f.x[:len(f.x):len(f.x)], true
+	}
+	f.x = append(f.x, x...)
+
+	for i := range x {
+		if n := f.g.Length(f.g.cell(x[i])); n > f.maxLength {
+			f.maxLength = n
+		}
+	}
 }
 
 // Reuse resuses as much of the internal arrays of f as possible to create a new
-// finder for the input set of positions.
-func (f *Finder) Reuse(x [][3]float32) {
-	b, cells  := getBounds(x, f.g.Width, f.g.Cells)
+// finder for the input set of positions. See FinderCursorOf for how this
+// affects existing cursors.
+func (f *FinderOf[F, I]) Reuse(x [][3]F) {
+	b, cells  := getBounds(x, &f.g.Box, f.g.Cells)
 	f.g.Reuse(b, cells, len(x))
 	f.g.Insert(x)
 	
-	f.gBuf = make([]int32, f.g.MaxLength())
-	f.idxBuf = []int32{ }
+	f.maxLength = f.g.MaxLength()
 	f.x = x
 	f.cells = cells
+	f.ownsX = false
 }
 
 // FindSubhalos links grid halos (from group A) to a target halo (from group B).
 // Returned array is an internal buffer, so please treat it kindly.
-func (sf *Finder) Find(pos [3]float32, r0 float32) []int32 {
-	if 2*r0 >= sf.g.Width {
+func (sf *FinderCursorOf[F, I]) Find(pos [3]F, r0 F) []I {
+	if maxR := sf.g.maxR; r0 >= maxR {
 		panic(fmt.Sprintf("Finder cannot do searches with radius %g in a box " +
-			"with width %g.", r0, sf.g.Width))
+			"with width %g.", r0, 2*maxR))
 	}
 	
 	sf.idxBuf = sf.idxBuf[:0]
 
 	b := &Bounds{}
+	sf.g.sphereBounds(b, pos, r0)
+
+	// This is the hottest loop in FOF, so cells' linked lists are walked
+	// directly instead of being copied out with readCell.
+	for _, idx := range sf.cellsIn(b) {
+		sf.addSubhalos(idx, pos, r0)
+	}
+
+	return sf.idxBuf
+}
+
+// FindDist returns the indices of every point within r of pos along with
+// their distances, which account for periodic axes. If sorted is true, the
+// points are sorted by distance. The returned arrays are internal buffers,
+// so please treat them kindly.
+func (sf *FinderCursorOf[F, I]) FindDist(pos [3]F, r F, sorted bool) ([]I, []F) {
+	sf.findDist2(pos, r, sorted, false)
+	for i := range sf.dr2Buf {
+		sf.dr2Buf[i] = F(math.Sqrt(float64(sf.dr2Buf[i])))
+	}
+	return sf.idxBuf, sf.dr2Buf
+}
+
+// FindSep returns the indices of every point within r of pos along with
+// their separations from pos, which account for periodic axes. If sorted is
+// true, the points are sorted by distance. The returned arrays are internal
+// buffers, so please treat them kindly.
+func (sf *FinderCursorOf[F, I]) FindSep(pos [3]F, r F, sorted bool) ([]I, [][3]F) {
+	sf.findDist2(pos, r, sorted, true)
+	return sf.idxBuf, sf.sepBuf
+}
+
+// findDist2 fills the index and squared distance buffers with every point
+// within r of pos, along with the separation buffer if withSep is true.
+func (sf *FinderCursorOf[F, I]) findDist2(pos [3]F, r F, sorted, withSep bool) {
+	if maxR := sf.g.Box.MaxRadius(); r >= maxR {
+		panic(fmt.Sprintf("Finder cannot do searches with radius %g in a box " +
+			"with width %g.", r, 2*maxR))
+	}
+
+	sf.idxBuf, sf.dr2Buf = sf.idxBuf[:0], sf.dr2Buf[:0]
+	sf.sepBuf = sf.sepBuf[:0]
+	pL2, L := sf.g.Box.halfWidths(), sf.g.Box.Width
+
+	b := &Bounds{}
+	boxBounds(b, pos, [3]F{ r, r, r }, sf.cells, &sf.g.Box)
+	for _, idx := range sf.cellsIn(b) {
+		for _, j := range sf.readCell(idx) {
+			dx, dr2 := [3]F{ }, F(0)
+			for k := 0; k < 3; k++ {
+				dx[k] = sf.x[j][k] - pos[k]
+				if dx[k] > pL2[k] {
+					dx[k] -= L[k]
+				} else if dx[k] < -pL2[k] {
+					dx[k] += L[k]
+				}
+				dr2 += dx[k]*dx[k]
+			}
+			if dr2 > r*r { continue }
+
+			sf.idxBuf = append(sf.idxBuf, j)
+			sf.dr2Buf = append(sf.dr2Buf, dr2)
+			if withSep { sf.sepBuf = append(sf.sepBuf, dx) }
+		}
+	}
+
+	if sorted {
+		order := &distOrder[F, I]{ sf.idxBuf, sf.dr2Buf, nil }
+		if withSep { order.sep = sf.sepBuf }
+		sort.Sort(order)
+	}
+}
+
+// cellsIn returns the index of every grid cell within b which is covered by
+// the grid. The returned array is an internal buffer.
+func (sf *FinderCursorOf[F, I]) cellsIn(b *Bounds) []int {
 	c := sf.cells
 	g := sf.g
-	
-	b.SphereBounds(pos, r0, g.cw, sf.g.Width)
+	sf.cellBuf = sf.cellBuf[:0]
 
 	for dz := 0; dz < b.Span[2]; dz++ {
 		z := b.Origin[2] + dz
-		if z >= c { z -= c }
+		if z >= c[2] { z -= c[2] }
 		
 		if z < g.Origin[2] || z >= g.Span[2] + g.Origin[2] { continue }
 		zOff := (z - g.Origin[2])*g.Span[0]*g.Span[1]
 		
 		for dy := 0; dy < b.Span[1]; dy++ {
 			y := b.Origin[1] + dy
-			if y >= c { y -= c }
+			if y >= c[1] { y -= c[1] }
 			
 			if y < g.Origin[1] || y >= g.Span[1] + g.Origin[1] { continue }
 			yOff := (y - g.Origin[1])*g.Span[0]
 			
 			for dx := 0; dx < b.Span[0]; dx++ {
 				x := b.Origin[0] + dx
-				if x >= c { x -= c }
+				if x >= c[0] { x -= c[0] }
 
 				if x < g.Origin[0] || x >= g.Span[0] + g.Origin[0] { continue }
 				xOff := x - g.Origin[0]
 				
-				idx := zOff + yOff + xOff
-
-				sf.gBuf = sf.g.ReadIndices(idx, sf.gBuf)
-				sf.addSubhalos(sf.gBuf, pos[0], pos[1], pos[2], r0, sf.g.Width)
+				sf.cellBuf = append(sf.cellBuf, zOff + yOff + xOff)
 			}
 		}
 	}
 
-	return sf.idxBuf
+	return sf.cellBuf
 }
 
-func (sf *Finder) addSubhalos(
-	idxs []int32, xh, yh, zh, rh float32, L float32,
-) {
-	pL2, nL2 := L/2, -L/2
-	for _, j := range idxs {
-		sx, sy, sz := sf.x[j][0], sf.x[j][1], sf.x[j][2]
+// addSubhalos adds the points in grid cell idx which are within rh of pos to
+// the index buffer.
+func (sf *FinderCursorOf[F, I]) addSubhalos(idx int, pos [3]F, rh F) {
+	xh, yh, zh := pos[0], pos[1], pos[2]
+	// Non-periodic axes have infinite half-widths, so they never wrap.
+	pL2, L := sf.g.hw, sf.g.Box.Width
+	// Copying these out of sf keeps appends to idxBuf from forcing them to
+	// be reloaded for every point.
+	x, next, buf := sf.x, sf.g.Next, sf.idxBuf
+	for j := sf.g.Heads[idx]; j != listEnd; j = next[j] {
+		sx, sy, sz := x[j][0], x[j][1], x[j][2]
 		dx, dy, dz, dr := xh-sx, yh-sy, zh-sz, rh
 
-		if dx > pL2 {
-			dx -= L
-		} else if dx < nL2 {
-			dx += L
+		if dx > pL2[0] {
+			dx -= L[0]
+		} else if dx < -pL2[0] {
+			dx += L[0]
 		}
 
-		if dy > pL2 {
-			dy -= L
-		} else if dy < nL2 {
-			dy += L
+		if dy > pL2[1] {
+			dy -= L[1]
+		} else if dy < -pL2[1] {
+			dy += L[1]
 		}
 
-		if dz > pL2 {
-			dz -= L
-		} else if dz < nL2 {
-			dz += L
+		if dz > pL2[2] {
+			dz -= L[2]
+		} else if dz < -pL2[2] {
+			dz += L[2]
 		}
 		
 		dr2 := dx*dx + dy*dy + dz*dz
 		
 		if dr*dr >= dr2 {
-			sf.idxBuf = append(sf.idxBuf, j)
+			buf = append(buf, j)
 		}
 	}
+	sf.idxBuf = buf
 }
@@ -0,0 +1,111 @@
+package symfof
+
+import (
+	"fmt"
+	"math"
+)
+
+// FindBox returns the indices of every point inside the axis-aligned box
+// centered on pos with half-widths h. The returned array is an internal
+// buffer, so please treat it kindly.
+func (sf *FinderCursorOf[F, I]) FindBox(pos, h [3]F) []I {
+	return sf.findShape(pos, h, func(dx [3]F) bool {
+		return abs(dx[0]) <= h[0] && abs(dx[1]) <= h[1] && abs(dx[2]) <= h[2]
+	})
+}
+
+// FindEllipsoid returns the indices of every point inside an ellipsoid
+// centered on pos. axes are the orthonormal directions of the ellipsoid's
+// principal axes and r are the lengths of the corresponding semi-axes. The
+// returned array is an internal buffer, so please treat it kindly.
+func (sf *FinderCursorOf[F, I]) FindEllipsoid(pos [3]F, axes [3][3]F, r [3]F) []I {
+	h := [3]F{ }
+	for d := 0; d < 3; d++ {
+		h2 := F(0)
+		for k := 0; k < 3; k++ {
+			hk := r[k]*axes[k][d]
+			h2 += hk*hk
+		}
+		h[d] = F(math.Sqrt(float64(h2)))
+	}
+
+	return sf.findShape(pos, h, func(dx [3]F) bool {
+		sum := F(0)
+		for k := 0; k < 3; k++ {
+			proj := (dx[0]*axes[k][0] + dx[1]*axes[k][1] + dx[2]*axes[k][2])/r[k]
+			sum += proj*proj
+		}
+		return sum <= 1
+	})
+}
+
+// FindCylinder returns the indices of every point inside a cylinder with
+// radius r whose axis runs through pos in the direction of axis. The
+// cylinder extends halfLength from pos in both directions. axis does not
+// need to be normalized, but it must have a non-zero, finite length. The
+// returned array is an internal buffer, so please treat it kindly.
+func (sf *FinderCursorOf[F, I]) FindCylinder(pos, axis [3]F, r, halfLength F) []I {
+	norm := F(math.Sqrt(float64(axis[0]*axis[0] + axis[1]*axis[1] +
+		axis[2]*axis[2])))
+	if !(norm > 0) || math.IsInf(float64(norm), 0) {
+		panic(fmt.Sprintf("FindCylinder cannot search along the axis %g, " +
+			"which has length %g.", axis, norm))
+	}
+	for d := 0; d < 3; d++ { axis[d] /= norm }
+
+	h := [3]F{ }
+	for d := 0; d < 3; d++ {
+		sin := F(math.Sqrt(math.Max(0, float64(1 - axis[d]*axis[d]))))
+		h[d] = halfLength*abs(axis[d]) + r*sin
+	}
+
+	return sf.findShape(pos, h, func(dx [3]F) bool {
+		along := dx[0]*axis[0] + dx[1]*axis[1] + dx[2]*axis[2]
+		dr2 := dx[0]*dx[0] + dx[1]*dx[1] + dx[2]*dx[2]
+		return abs(along) <= halfLength && dr2 - along*along <= r*r
+	})
+}
+
+// findShape returns the indices of every point within the axis-aligned box
+// centered on pos with half-widths h for which inside returns true. inside
+// is given the separation from pos to the point, which accounts for periodic
+// axes.
+func (sf *FinderCursorOf[F, I]) findShape(
+	pos, h [3]F, inside func(dx [3]F) bool,
+) []I {
+	box := &sf.g.Box
+	for d := 0; d < 3; d++ {
+		if box.Periodic[d] && h[d] >= box.Width[d]/2 {
+			panic(fmt.Sprintf("Finder cannot do searches with a half-width " +
+				"of %g along an axis with width %g.", h[d], box.Width[d]))
+		}
+	}
+
+	sf.idxBuf = sf.idxBuf[:0]
+	pL2, L := box.halfWidths(), box.Width
+
+	b := &Bounds{ }
+	boxBounds(b, pos, h, sf.cells, box)
+	for _, idx := range sf.cellsIn(b) {
+		for _, j := range sf.readCell(idx) {
+			dx := [3]F{ }
+			for d := 0; d < 3; d++ {
+				dx[d] = sf.x[j][d] - pos[d]
+				if dx[d] > pL2[d] {
+					dx[d] -= L[d]
+				} else if dx[d] < -pL2[d] {
+					dx[d] += L[d]
+				}
+			}
+			if inside(dx) { sf.idxBuf = append(sf.idxBuf, j) }
+		}
+	}
+
+	return sf.idxBuf
+}
+
+// abs returns the absolute value of x.
+func abs[F Float](x F) F {
+	if x < 0 { return -x }
+	return x
+}
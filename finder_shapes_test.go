@@ -0,0 +1,113 @@
+package symfof
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// bruteShape returns the indices of every point in x whose separation from
+// pos in box passes inside.
+func bruteShape(
+	box *Box, x [][3]float32, pos [3]float32, inside func(dx [3]float32) bool,
+) []int32 {
+	out := []int32{ }
+	for i := range x {
+		dx := [3]float32{ }
+		for k := 0; k < 3; k++ { dx[k] = box.SymBound(x[i][k] - pos[k], k) }
+		if inside(dx) { out = append(out, int32(i)) }
+	}
+	return out
+}
+
+// sameIndices returns true if a and b contain the same indices in any order.
+func sameIndices(a, b []int32) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return Int32Eq(a, b)
+}
+
+func TestFinderShapes(t *testing.T) {
+	width := [3]float32{ 20, 30, 40 }
+	x := randomPoints(3000, 1, 41)
+	for i := range x {
+		for k := 0; k < 3; k++ { x[i][k] *= width[k] }
+	}
+	centers := [][3]float32{ {10, 15, 20}, {0.5, 29, 1}, {19.5, 0, 39} }
+
+	// An ellipsoid rotated by 30 degrees around the z-axis.
+	c, s := float32(math.Cos(math.Pi/6)), float32(math.Sin(math.Pi/6))
+	axes := [3][3]float32{ {c, s, 0}, {-s, c, 0}, {0, 0, 1} }
+	r := [3]float32{ 6, 3, 2 }
+	h := [3]float32{ 4, 5, 3 }
+	axis := [3]float32{ 1, 2, 2 } // Length 3
+	rCyl, halfLength := float32(2), float32(7)
+
+	boxes := []*Box{
+		RectBox(width, [3]bool{ true, true, true }),
+		RectBox(width, [3]bool{ true, false, true }),
+		OpenBox(x),
+	}
+
+	for ib, box := range boxes {
+		f := NewFinderBox(box, x, 12)
+		for ic, pos := range centers {
+			idx := f.FindBox(pos, h)
+			exp := bruteShape(box, x, pos, func(dx [3]float32) bool {
+				return abs(dx[0]) <= h[0] && abs(dx[1]) <= h[1] &&
+					abs(dx[2]) <= h[2]
+			})
+			if !sameIndices(idx, exp) {
+				t.Errorf("%d, %d) FindBox gave %d points, expected %d.",
+					ib, ic, len(idx), len(exp))
+			}
+
+			idx = f.FindEllipsoid(pos, axes, r)
+			exp = bruteShape(box, x, pos, func(dx [3]float32) bool {
+				sum := float32(0)
+				for k := 0; k < 3; k++ {
+					proj := dx[0]*axes[k][0] + dx[1]*axes[k][1] +
+						dx[2]*axes[k][2]
+					sum += proj*proj/(r[k]*r[k])
+				}
+				return sum <= 1
+			})
+			if !sameIndices(idx, exp) {
+				t.Errorf("%d, %d) FindEllipsoid gave %d points, expected %d.",
+					ib, ic, len(idx), len(exp))
+			}
+
+			idx = f.FindCylinder(pos, axis, rCyl, halfLength)
+			exp = bruteShape(box, x, pos, func(dx [3]float32) bool {
+				along := (dx[0]*axis[0] + dx[1]*axis[1] + dx[2]*axis[2])/3
+				dr2 := dx[0]*dx[0] + dx[1]*dx[1] + dx[2]*dx[2]
+				return abs(along) <= halfLength &&
+					dr2 - along*along <= rCyl*rCyl
+			})
+			if !sameIndices(idx, exp) {
+				t.Errorf("%d, %d) FindCylinder gave %d points, expected %d.",
+					ib, ic, len(idx), len(exp))
+			}
+		}
+	}
+}
+
+func TestFindCylinderBadAxis(t *testing.T) {
+	L := float32(20)
+	f := NewFinder(L, randomPoints(100, L, 42), 10)
+	inf, nan := float32(math.Inf(+1)), float32(math.NaN())
+	axes := [][3]float32{ {0, 0, 0}, {inf, 0, 0}, {0, nan, 1} }
+
+	for _, axis := range axes {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected FindCylinder to panic on the axis %g.",
+						axis)
+				}
+			}()
+			f.FindCylinder([3]float32{ 10, 10, 10 }, axis, 2, 3)
+		}()
+	}
+}
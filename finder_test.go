@@ -1,6 +1,7 @@
 package symfof
 
 import (
+	"math"
 	"testing"
 )
 
@@ -55,3 +56,57 @@ func TestFinder(t *testing.T) {
 		t.Errorf("expected Find(1) to give %d, but got %d", idx1, idx)
 	}
 }
+
+func TestFinderFindDist(t *testing.T) {
+	L := float32(100)
+	x := randomPoints(2000, L, 43)
+	box := CubicBox(L)
+	f := NewFinder(L, x, 20)
+
+	centers := [][3]float32{ {50, 50, 50}, {1, 99, 0.5} }
+	for ic, pos := range centers {
+		exp := f.Find(pos, 8)
+		expIdx := append([]int32{ }, exp...)
+
+		idx, dist := f.FindDist(pos, 8, false)
+		if !Int32Eq(idx, expIdx) {
+			t.Errorf("%d) expected FindDist indices %d, got %d.",
+				ic, expIdx, idx)
+		}
+
+		idx, dist = f.FindDist(pos, 8, true)
+		if !sameIndices(idx, expIdx) {
+			t.Errorf("%d) sorted FindDist found different points than Find.",
+				ic)
+		}
+		for i := range idx {
+			dr := float32(math.Sqrt(float64(box.Dist2(x[idx[i]], pos))))
+			if !almostEq(dr, dist[i]) || (i > 0 && dist[i] < dist[i-1]) {
+				t.Errorf("%d) FindDist distances %g are wrong or unsorted.",
+					ic, dist)
+				break
+			}
+		}
+
+		idx, sep := f.FindSep(pos, 8, true)
+		if !sameIndices(idx, expIdx) {
+			t.Errorf("%d) FindSep found different points than Find.", ic)
+		}
+		for i := range idx {
+			for k := 0; k < 3; k++ {
+				dx := box.SymBound(x[idx[i]][k] - pos[k], k)
+				if !almostEq(dx, sep[i][k]) {
+					t.Errorf("%d) expected separation %d to have component " +
+						"%g, got %g.", ic, i, dx, sep[i][k])
+				}
+			}
+			dr2 := sep[i][0]*sep[i][0] + sep[i][1]*sep[i][1] +
+				sep[i][2]*sep[i][2]
+			if i > 0 && dr2 < sep[i-1][0]*sep[i-1][0] +
+				sep[i-1][1]*sep[i-1][1] + sep[i-1][2]*sep[i-1][2] {
+				t.Errorf("%d) FindSep separations are unsorted.", ic)
+				break
+			}
+		}
+	}
+}
@@ -1,34 +1,72 @@
 package symfof
 
-func FOF(L float32, x, cen [][3]float32, r float32, nGrid, nMin int) (groups, cenGroups []int32) {
-	f := NewFinder(L, x, nGrid)
-	uf := NewUnionFinder(int32(len(x)))
+func FOF[F Float](
+	L F, x, cen [][3]F, r F, nGrid, nMin int,
+) (groups, cenGroups []int32) {
+	return FOFBox(CubicBox(L), x, cen, r, nGrid, nMin)
+}
 
-	for i := int32(0); i < int32(len(x)); i++ {
-		idx := f.Find(x[i], r)
-		for _, j := range idx {
-			if i == j { continue }
-			uf.Union(i, j)
-		}
-	}
+// FOFBox runs FOF on points inside an arbitrary box. nGrid is the number of
+// grid cells along the widest axis of the box.
+func FOFBox[F Float](
+	box *BoxOf[F], x, cen [][3]F, r F, nGrid, nMin int,
+) (groups, cenGroups []int32) {
+	return FOFOf[F, int32](box, x, cen, r, nGrid, nMin)
+}
 
-	groups = make([]int32, len(x))
-	for i := range x {
-		groups[i] = uf.Find(int32(i))
-		if uf.Size[groups[i]] < int32(nMin) {
-			groups[i] = -1
-		}
-	}
+// FOFOf runs FOF on points inside an arbitrary box and returns group labels
+// of type I. int64 labels are needed above about 2.1 billion points.
+func FOFOf[F Float, I Index](
+	box *BoxOf[F], x, cen [][3]F, r F, nGrid, nMin int,
+) (groups, cenGroups []I) {
+	f := NewFinderOf[F, I](box, x, nGrid)
+	uf := NewUnionFinderOf(I(len(x)))
+	linkFinder(f, x, r, uf)
 
-	cenGroups = make([]int32, len(cen))
+	groups = groupLabels(uf, nMin)
+
+	cenGroups = make([]I, len(cen))
 	for i := range cenGroups {
-		idx := f.Find(cen[i], r)
+		// Centers go to the group of the nearest point.
+		idx, dist := f.FindDist(cen[i], r, false)
 		if len(idx) == 0 {
 			cenGroups[i] = -1
-		} else {
-			cenGroups[i] = groups[idx[0]]
+			continue
+		}
+
+		nearest := 0
+		for j := range dist {
+			if dist[j] < dist[nearest] { nearest = j }
 		}
+		cenGroups[i] = groups[idx[nearest]]
 	}
 
 	return groups, cenGroups
 }
+
+// groupLabels returns the root of each point's group, or -1 if that group has
+// fewer than nMin members.
+func groupLabels[I Index](uf *UnionFinderOf[I], nMin int) []I {
+	groups := make([]I, len(uf.Parent))
+	for i := range groups {
+		groups[i] = uf.Find(I(i))
+		if uf.Size[groups[i]] < I(nMin) {
+			groups[i] = -1
+		}
+	}
+	return groups
+}
+
+// linkFinder unions every pair of points in x that are within r of one
+// another, using a Finder built around x.
+func linkFinder[F Float, I Index](
+	f *FinderOf[F, I], x [][3]F, r F, uf *UnionFinderOf[I],
+) {
+	for i := I(0); i < I(len(x)); i++ {
+		idx := f.Find(x[i], r)
+		for _, j := range idx {
+			if i == j { continue }
+			uf.Union(i, j)
+		}
+	}
+}
@@ -75,3 +75,99 @@ func TestFOF(t *testing.T) {
 		}
 	}
 }
+
+func TestFOF64(t *testing.T) {
+	// Separations of 1e-3 can't be resolved by float32 this far from the
+	// origin.
+	L, r := float64(1e6), float64(2e-3)
+	x := [][3]float64{
+		{L - 10, 5e5, 5e5},
+		{L - 10 + 1e-3, 5e5, 5e5},
+		{L - 10 + 2e-3, 5e5, 5e5},
+		{L - 10 + 5e-3, 5e5, 5e5},
+		{L - 10 + 6e-3, 5e5, 5e5},
+		{L - 10 + 7e-3, 5e5, 5e5},
+	}
+	cen := [][3]float64{ {L - 10 + 6.5e-3, 5e5, 5e5} }
+
+	groups, cenGroups := FOF(L, x, cen, r, 100, 3)
+	exp := []int32{ 0, 0, 0, 3, 3, 3 }
+	if !samePartition(groups, exp) {
+		t.Errorf("Expected groups %d, got %d", exp, groups)
+	}
+	if cenGroups[0] != groups[3] {
+		t.Errorf("Expected central to be in group %d, found group %d",
+			groups[3], cenGroups[0])
+	}
+
+	// float32 and float64 should agree when float32 has enough precision.
+	x32 := randomPoints(2000, 50, 11)
+	x64 := make([][3]float64, len(x32))
+	for i := range x32 {
+		for k := 0; k < 3; k++ { x64[i][k] = float64(x32[i][k]) }
+	}
+	groups32, _ := FOF(float32(50), x32, nil, 1.5, 20, 3)
+	groups64, _ := FOF(float64(50), x64, nil, 1.5, 20, 3)
+	if !samePartition(groups32, groups64) {
+		t.Errorf("float32 and float64 FOF groups do not match.")
+	}
+}
+
+func TestFOFOfInt64(t *testing.T) {
+	L, r := float32(50), float32(1.5)
+	x := randomPoints(2000, L, 13)
+	cen := randomPoints(50, L, 14)
+
+	groups32, cenGroups32 := FOF(L, x, cen, r, 20, 3)
+	groups64, cenGroups64 := FOFOf[float32, int64](
+		CubicBox(L), x, cen, r, 20, 3,
+	)
+
+	for i := range groups32 {
+		if int64(groups32[i]) != groups64[i] {
+			t.Fatalf("Expected particle %d to have group %d, found group %d",
+				i, groups32[i], groups64[i])
+		}
+	}
+	for i := range cenGroups32 {
+		if int64(cenGroups32[i]) != cenGroups64[i] {
+			t.Fatalf("Expected central %d to have group %d, found group %d",
+				i, cenGroups32[i], cenGroups64[i])
+		}
+	}
+}
+
+func TestFOFNearestCenter(t *testing.T) {
+	L, r := float32(20), float32(1)
+	x := [][3]float32{
+		{5, 5, 5}, {5.5, 5, 5}, {6, 5, 5}, // Group A
+		{7.5, 5, 5}, {8, 5, 5}, {8.5, 5, 5}, // Group B
+		{19.8, 10, 10}, {0.3, 10, 10}, {0.8, 10, 10}, // Group C
+		{18.6, 10, 10}, {18.1, 10, 10}, {17.6, 10, 10}, // Group D
+	}
+	// Each center is within r of two groups, including across the periodic
+	// boundary, and should go to the one with the nearest point.
+	cen := [][3]float32{ {6.9, 5, 5}, {6.6, 5, 5}, {19.3, 10, 10} }
+	exp := []int32{ 3, 0, 6 }
+
+	groups, cenGroups := FOF(L, x, cen, r, 10, 3)
+	for i := range cen {
+		if cenGroups[i] != groups[exp[i]] {
+			t.Errorf("Expected center %d to be in the group of point %d, %d, " +
+				"got %d.", i, exp[i], groups[exp[i]], cenGroups[i])
+		}
+	}
+}
+
+// BenchmarkFOF32 runs float32 FOF at a typical density. Its linking length
+// makes each search cover a handful of grid cells, so it mostly times
+// Finder.Find.
+func BenchmarkFOF32(b *testing.B) {
+	L, r := float32(100), float32(0.3)
+	x := randomPoints(200000, L, 15)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FOF(L, x, nil, r, 64, 1)
+	}
+}
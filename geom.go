@@ -1,24 +1,38 @@
 package symfof
 
-func Bound(dx, L float32) float32 {
-	if dx > L { return dx - L }
-	if dx < 0 { return dx + L }
+import (
+	"math"
+)
+
+// Bound wraps a position into [0, L). A position of exactly L wraps to 0, as
+// does a tiny negative position which rounds to L when L is added, so
+// bounded positions always fall inside a grid cell.
+func Bound[F Float](dx, L F) F {
+	if dx >= L { return dx - L }
+	if dx < 0 {
+		dx += L
+		if dx >= L { return 0 }
+	}
 	return dx
 }
 
-func SymBound(dx, L float32) float32 {
+func SymBound[F Float](dx, L F) F {
 	if dx > +L/2 { return dx - L }
 	if dx < -L/2 { return dx + L }
 	return dx
 }
 
-type FloatBounds struct {
-	Origin, Span [3]float32
+// FloatBoundsOf is a bounding box which is not aligned to grid cells.
+type FloatBoundsOf[F Float] struct {
+	Origin, Span [3]F
 }
 
+// FloatBounds is a FloatBoundsOf with float32 coordinates.
+type FloatBounds = FloatBoundsOf[float32]
+
 // PointBoundsNonPeriodic calculates a bounding box around a set of points
 // which does not span box boundaries.
-func PointBoundsNonPeriodic(x [][3]float32) *FloatBounds {
+func PointBoundsNonPeriodic[F Float](x [][3]F) *FloatBoundsOf[F] {
 	min, max := x[0], x[0]
 	for i := 1; i < len(x); i++ {
 		for k := 0; k < 3; k++ {
@@ -31,7 +45,7 @@ func PointBoundsNonPeriodic(x [][3]float32) *FloatBounds {
 		}
 	}
 	
-	b := &FloatBounds{ }
+	b := &FloatBoundsOf[F]{ }
 	b.Origin = min
 	for k := 0; k < 3; k++ {
 		b.Span[k] = max[k] - min[k]
@@ -47,8 +61,8 @@ type Bounds struct {
 
 // FromFloatBounds converts a FloatBounds object to a Bounds object within
 // a grid with a given cell width, cw.
-func FloatBoundsToIntBounds(fb *FloatBounds, cw float32) *Bounds {
-	min, max := fb.Origin, [3]float32{ }
+func FloatBoundsToIntBounds[F Float](fb *FloatBoundsOf[F], cw F) *Bounds {
+	min, max := fb.Origin, [3]F{ }
 	for k := 0; k < 3; k++ { max[k] = min[k] + fb.Span[k] }
 
 	b := &Bounds{ }
@@ -105,3 +119,170 @@ func (b *Bounds) Inside(val int, width int, dim int) bool {
 	}
 	return val < hi && val >= lo
 }
+
+// BoxOf describes the volume that a set of points lives in. Each axis has its
+// own width and may or may not be periodic. Points must be inside
+// [Origin, Origin + Width) along periodic axes. Along non-periodic axes,
+// points outside this range are allowed, but are treated as if they were on
+// its edge when binning.
+type BoxOf[F Float] struct {
+	Origin, Width [3]F
+	Periodic      [3]bool
+}
+
+// Box is a BoxOf with float32 coordinates.
+type Box = BoxOf[float32]
+
+// CubicBox returns a periodic cube with width L whose origin is at zero.
+func CubicBox[F Float](L F) *BoxOf[F] {
+	return &BoxOf[F]{
+		Width: [3]F{ L, L, L },
+		Periodic: [3]bool{ true, true, true },
+	}
+}
+
+// RectBox returns a box with the given side lengths whose origin is at zero.
+// Each axis is periodic if the corresponding element of periodic is true.
+func RectBox[F Float](width [3]F, periodic [3]bool) *BoxOf[F] {
+	return &BoxOf[F]{ Width: width, Periodic: periodic }
+}
+
+// OpenBox returns a box with no periodic axes that tightly encloses x. If x
+// is empty, the box has zero width.
+func OpenBox[F Float](x [][3]F) *BoxOf[F] {
+	if len(x) == 0 { return &BoxOf[F]{ } }
+	fb := PointBoundsNonPeriodic(x)
+	b := &BoxOf[F]{ Origin: fb.Origin }
+	for k := 0; k < 3; k++ {
+		b.Width[k] = openWidth(fb.Span[k])
+	}
+	return b
+}
+
+// openWidth returns the width of a non-periodic axis which encloses points
+// that span the distance span. The upper edge is padded so the furthest
+// points are inside the box.
+func openWidth[F Float](span F) F {
+	return span*1.0001 + 1e-6
+}
+
+// SymBound returns the separation dx along axis k, wrapped into
+// [-Width/2, +Width/2] if the axis is periodic.
+func (b *BoxOf[F]) SymBound(dx F, k int) F {
+	if !b.Periodic[k] { return dx }
+	return SymBound(dx, b.Width[k])
+}
+
+// Bound wraps the position x along axis k into the box if the axis is
+// periodic.
+func (b *BoxOf[F]) Bound(x F, k int) F {
+	if !b.Periodic[k] { return x }
+	return Bound(x - b.Origin[k], b.Width[k]) + b.Origin[k]
+}
+
+// Dist2 returns the squared distance between two points in the box.
+func (b *BoxOf[F]) Dist2(x1, x2 [3]F) F {
+	dr2 := F(0)
+	for k := 0; k < 3; k++ {
+		dx := b.SymBound(x1[k] - x2[k], k)
+		dr2 += dx*dx
+	}
+	return dr2
+}
+
+// MaxRadius returns the largest search radius that doesn't overlap with its
+// own periodic images. It is infinite if no axes are periodic.
+func (b *BoxOf[F]) MaxRadius() F {
+	r := F(math.Inf(+1))
+	for k := 0; k < 3; k++ {
+		if b.Periodic[k] && b.Width[k]/2 < r { r = b.Width[k]/2 }
+	}
+	return r
+}
+
+// Cells returns the number of grid cells along each axis if there are
+// cells cells along the widest axis. Cells are as close to cubic as
+// periodic axes allow.
+func (b *BoxOf[F]) Cells(cells int) [3]int {
+	maxWidth := b.Width[0]
+	if maxWidth < b.Width[1] { maxWidth = b.Width[1] }
+	if maxWidth < b.Width[2] { maxWidth = b.Width[2] }
+
+	out := [3]int{ }
+	for k := 0; k < 3; k++ {
+		out[k] = int(math.Ceil(
+			float64(cells)*float64(b.Width[k])/float64(maxWidth)))
+		if out[k] < 1 { out[k] = 1 }
+	}
+	return out
+}
+
+// halfWidths returns half of the width of each periodic axis and infinity for
+// each non-periodic axis, so that wrapping separations against them is a
+// no-op along non-periodic axes.
+func (b *BoxOf[F]) halfWidths() [3]F {
+	h := [3]F{ }
+	for k := 0; k < 3; k++ {
+		if b.Periodic[k] {
+			h[k] = b.Width[k]/2
+		} else {
+			h[k] = F(math.Inf(+1))
+		}
+	}
+	return h
+}
+
+// CellBounds converts a FloatBounds object to a Bounds object within a grid
+// spanning the box with the given number of cells along each axis.
+func (b *BoxOf[F]) CellBounds(fb *FloatBoundsOf[F], cells [3]int) *Bounds {
+	out := &Bounds{ }
+	for k := 0; k < 3; k++ {
+		lo := b.cellIndex(fb.Origin[k], k, cells[k])
+		hi := b.cellIndex(fb.Origin[k] + fb.Span[k], k, cells[k])
+		out.Origin[k], out.Span[k] = lo, hi - lo + 1
+	}
+	return out
+}
+
+// cellIndex returns the index of the cell containing x along axis k, if there
+// are cells cells along that axis.
+func (b *BoxOf[F]) cellIndex(x F, k, cells int) int {
+	cw := b.Width[k]/F(cells)
+	// Clamping before converting lets truncation stand in for math.Floor
+	// without leaving F's precision.
+	u := (x - b.Origin[k])/cw
+	if u < 0 { return 0 }
+	if u >= F(cells) { return cells - 1 }
+	return int(u)
+}
+
+// BoxBounds creates a cell-aligned bounding box around an axis-aligned box
+// with half-widths h centered on pos. The grid spans box with the given
+// number of cells along each axis. Bounds wrap across periodic axes, but are
+// clipped to the grid along non-periodic ones.
+func (b *Bounds) BoxBounds(pos, h [3]float32, cells [3]int, box *Box) {
+	boxBounds(b, pos, h, cells, box)
+}
+
+// boxBounds implements Bounds.BoxBounds for any coordinate type.
+func boxBounds[F Float](b *Bounds, pos, h [3]F, cells [3]int, box *BoxOf[F]) {
+	for k := 0; k < 3; k++ {
+		if !box.Periodic[k] {
+			lo := box.cellIndex(pos[k] - h[k], k, cells[k])
+			hi := box.cellIndex(pos[k] + h[k], k, cells[k])
+			b.Origin[k], b.Span[k] = lo, hi - lo + 1
+			continue
+		}
+
+		cw := box.Width[k]/F(cells[k])
+		min, max := pos[k] - h[k] - box.Origin[k], pos[k] + h[k] - box.Origin[k]
+		if min < 0 {
+			min += box.Width[k]
+			max += box.Width[k]
+		}
+
+		minCell, maxCell := int(min/cw), int(max/cw)
+		b.Origin[k] = minCell
+		b.Span[k] = maxCell - minCell + 1
+	}
+}
@@ -0,0 +1,147 @@
+package symfof
+
+import (
+	"testing"
+)
+
+// bruteFOFBox links every pair of points within r of each other in box by
+// checking all pairs.
+func bruteFOFBox(box *Box, x [][3]float32, r float32, nMin int) []int32 {
+	uf := NewUnionFinder(int32(len(x)))
+	for i := range x {
+		for j := i + 1; j < len(x); j++ {
+			if box.Dist2(x[i], x[j]) <= r*r { uf.Union(int32(i), int32(j)) }
+		}
+	}
+	return groupLabels(uf, nMin)
+}
+
+func TestBound(t *testing.T) {
+	tests := []struct{
+		x, out float32
+	} {
+		{ 0, 0 }, { 3, 3 }, { 9.5, 9.5 }, { 10, 0 }, { 12, 2 },
+		{ -1, 9 }, { -10, 0 },
+		// -1e-7 + 10 rounds to 10.
+		{ -1e-7, 0 },
+	}
+
+	for i := range tests {
+		if out := Bound(tests[i].x, 10); out != tests[i].out {
+			t.Errorf("%d) expected Bound(%g, 10) = %g, got %g.", i,
+				tests[i].x, tests[i].out, out)
+		}
+	}
+
+	// Positions right on the edge of a periodic box wrap to zero, too.
+	box := CubicBox(float32(10))
+	if x := box.Bound(10, 0); x != 0 {
+		t.Errorf("expected Box.Bound(10, 0) = 0, got %g.", x)
+	}
+}
+
+func TestBoxSymBound(t *testing.T) {
+	box := RectBox([3]float32{ 10, 20, 30 }, [3]bool{ true, false, true })
+
+	tests := []struct{
+		dx float32
+		k int
+		out float32
+	} {
+		{ 1, 0, 1 }, { 9, 0, -1 }, { -9, 0, 1 },
+		{ 19, 1, 19 }, { -19, 1, -19 },
+		{ 16, 2, -14 }, { -16, 2, 14 },
+	}
+
+	for i := range tests {
+		out := box.SymBound(tests[i].dx, tests[i].k)
+		if out != tests[i].out {
+			t.Errorf("%d) expected SymBound(%g, %d) = %g, got %g.", i,
+				tests[i].dx, tests[i].k, tests[i].out, out)
+		}
+	}
+
+	if r := box.MaxRadius(); r != 5 {
+		t.Errorf("expected MaxRadius() = 5, got %g.", r)
+	}
+	if cells := box.Cells(30); cells != [3]int{ 10, 20, 30 } {
+		t.Errorf("expected Cells(30) = [10 20 30], got %d.", cells)
+	}
+}
+
+func TestFinderBoxNonPeriodic(t *testing.T) {
+	box := RectBox([3]float32{ 10, 10, 10 }, [3]bool{ false, true, true })
+	x := [][3]float32{
+		{0.5, 5, 5}, {9.5, 5, 5}, // Close only through the x-edge.
+		{5, 0.5, 5}, {5, 9.5, 5}, // Close through the periodic y-edge.
+	}
+
+	f := NewFinderBox(box, x, 10)
+	if idx := f.Find(x[0], 2); !Int32Eq(idx, []int32{ 0 }) {
+		t.Errorf("expected non-periodic Find to give [0], got %d.", idx)
+	}
+	if idx := f.Find(x[2], 2); !Int32Eq(idx, []int32{ 3, 2 }) {
+		t.Errorf("expected periodic Find to give [3 2], got %d.", idx)
+	}
+}
+
+func TestFOFBox(t *testing.T) {
+	r := float32(1.5)
+	x := randomPoints(2000, 1, 7)
+	width := [3]float32{ 20, 35, 50 }
+	for i := range x {
+		for k := 0; k < 3; k++ { x[i][k] *= width[k] }
+	}
+
+	boxes := []*Box{
+		RectBox(width, [3]bool{ true, true, true }),
+		RectBox(width, [3]bool{ true, false, true }),
+		RectBox(width, [3]bool{ false, false, false }),
+		OpenBox(x),
+	}
+
+	for i, box := range boxes {
+		groups, _ := FOFBox(box, x, nil, r, 20, 3)
+		brute := bruteFOFBox(box, x, r, 3)
+		if !samePartition(groups, brute) {
+			t.Errorf("%d) FOFBox groups do not match brute force linking.", i)
+		}
+	}
+}
+
+func TestCellFOFBox(t *testing.T) {
+	r := float32(1.5)
+	x := randomPoints(2000, 1, 8)
+	width := [3]float32{ 20, 35, 50 }
+	for i := range x {
+		for k := 0; k < 3; k++ { x[i][k] *= width[k] }
+	}
+	// Non-periodic boxes don't need to enclose every point.
+	x = append(x, [3]float32{ -1, 10, 10 }, [3]float32{ 0.2, 10, 10 })
+
+	boxes := []*Box{
+		RectBox(width, [3]bool{ true, true, true }),
+		RectBox(width, [3]bool{ true, false, true }),
+		RectBox(width, [3]bool{ false, false, false }),
+		OpenBox(x),
+	}
+
+	for i, box := range boxes {
+		xi := x
+		if box.Periodic[0] { xi = x[:len(x) - 2] }
+
+		groups, cenGroups := CellFOFBox(box, xi, xi[:50], r, 3)
+		brute := bruteFOFBox(box, xi, r, 3)
+		if !samePartition(groups, brute) {
+			t.Errorf("%d) CellFOFBox groups do not match brute force " +
+				"linking.", i)
+		}
+		for j := range cenGroups {
+			if cenGroups[j] != groups[j] {
+				t.Errorf("%d) center %d is on top of a point in group %d, " +
+					"but was assigned to %d.", i, j, groups[j], cenGroups[j])
+				break
+			}
+		}
+	}
+}
@@ -1,25 +1,49 @@
 package symfof
 
-type Grid struct {
+import (
+	"fmt"
+)
+
+// GridOf is a linked-list grid of point indices over a region of a box.
+// Positions have type F and indices have type I.
+type GridOf[F Float, I Index] struct {
 	Bounds
-	Cells     int
-	cw, Width float32
+	// Box is the volume the grid spans and Cells is the number of cells
+	// along each of its axes.
+	Box   BoxOf[F]
+	Cells [3]int
+	// cw is the width of a cell along each axis, hw is Box.halfWidths()
+	// and maxR is Box.MaxRadius(). They're cached for Finder searches.
+	cw, hw [3]F
+	maxR   F
 
 	// Grid-sized
-	Heads []int32
+	Heads []I
 	// Data-sized
-	Next []int32
+	Next []I
+}
+
+// Grid is a GridOf for float32 positions and int32 indices.
+type Grid = GridOf[float32, int32]
+
+func NewGrid[F Float](
+	b *Bounds, box *BoxOf[F], cells [3]int, dataLen int,
+) *GridOf[F, int32] {
+	return NewGridOf[F, int32](b, box, cells, dataLen)
 }
 
-func NewGrid(b *Bounds, cells int, width float32, dataLen int) *Grid {
-	g := &Grid{
+// NewGridOf creates a GridOf with the given index type.
+func NewGridOf[F Float, I Index](
+	b *Bounds, box *BoxOf[F], cells [3]int, dataLen int,
+) *GridOf[F, I] {
+	g := &GridOf[F, I]{
 		Bounds: *b,
+		Box: *box,
 		Cells: cells,
-		cw:    width / float32(cells),
-		Width: width,
-		Heads: make([]int32, b.Span[0]*b.Span[1]*b.Span[2]),
-		Next:  make([]int32, dataLen),
+		Heads: make([]I, b.Span[0]*b.Span[1]*b.Span[2]),
+		Next:  make([]I, dataLen),
 	}
+	g.setCellWidths()
 
 	for i := range g.Heads {
 		g.Heads[i] = listEnd
@@ -28,7 +52,38 @@ func NewGrid(b *Bounds, cells int, width float32, dataLen int) *Grid {
 	return g
 }
 
-func (g *Grid) Reuse(b *Bounds, cells int, dataLen int) {
+func (g *GridOf[F, I]) setCellWidths() {
+	for k := 0; k < 3; k++ {
+		g.cw[k] = g.Box.Width[k] / F(g.Cells[k])
+	}
+	g.hw, g.maxR = g.Box.halfWidths(), g.Box.MaxRadius()
+}
+
+// sphereBounds sets b to the cells which overlap the cube of half-width r
+// centered on pos. It gives the same result as boxBounds, but uses the
+// grid's cached cell widths.
+func (g *GridOf[F, I]) sphereBounds(b *Bounds, pos [3]F, r F) {
+	for k := 0; k < 3; k++ {
+		if !g.Box.Periodic[k] {
+			lo := g.Box.cellIndex(pos[k] - r, k, g.Cells[k])
+			hi := g.Box.cellIndex(pos[k] + r, k, g.Cells[k])
+			b.Origin[k], b.Span[k] = lo, hi - lo + 1
+			continue
+		}
+
+		min, max := pos[k] - r - g.Box.Origin[k], pos[k] + r - g.Box.Origin[k]
+		if min < 0 {
+			min += g.Box.Width[k]
+			max += g.Box.Width[k]
+		}
+
+		minCell, maxCell := int(min/g.cw[k]), int(max/g.cw[k])
+		b.Origin[k] = minCell
+		b.Span[k] = maxCell - minCell + 1
+	}
+}
+
+func (g *GridOf[F, I]) Reuse(b *Bounds, cells [3]int, dataLen int) {
 	newHeads := g.Heads[:0]
 	nHeads := b.Span[0]*b.Span[1]*b.Span[2]
 	newNext := g.Next[:0]
@@ -37,24 +92,24 @@ func (g *Grid) Reuse(b *Bounds, cells int, dataLen int) {
 		newHeads = newHeads[:nHeads]
 	} else {
 		newHeads = newHeads[:cap(newHeads)]
-		newHeads = append(newHeads, make([]int32, nHeads - cap(newHeads))...)
+		newHeads = append(newHeads, make([]I, nHeads - cap(newHeads))...)
 	}
 
 	if cap(newNext) > dataLen {
 		newNext = newNext[:dataLen]
 	} else {
 		newNext = newNext[:cap(newNext)]
-		newNext = append(newNext, make([]int32, dataLen - cap(newNext))...)
+		newNext = append(newNext, make([]I, dataLen - cap(newNext))...)
 	}
 	
-	*g = Grid{
+	*g = GridOf[F, I]{
 		Bounds: *b,
+		Box: g.Box,
 		Cells: cells,
-		cw: g.Width / float32(cells),
-		Width: g.Width,
 		Heads: newHeads,
 		Next: newNext,
 	}
+	g.setCellWidths()
 
 	for i := range g.Heads {
 		g.Heads[i] = listEnd
@@ -62,7 +117,7 @@ func (g *Grid) Reuse(b *Bounds, cells int, dataLen int) {
 	
 }
 
-func (g *Grid) Length(idx int) int {
+func (g *GridOf[F, I]) Length(idx int) int {
 	next := g.Heads[idx]
 	n := 0
 	for next != listEnd {
@@ -72,25 +127,47 @@ func (g *Grid) Length(idx int) int {
 	return n
 }
 
-func (g *Grid) Insert(xs [][3]float32) {
+func (g *GridOf[F, I]) Insert(xs [][3]F) {
 	for i := range xs {
-		x, y, z := xs[i][0], xs[i][1], xs[i][2]
-
-		ix, iy, iz := int(x/g.cw), int(y/g.cw), int(z/g.cw)
-		idx := (ix - g.Origin[0]) +
-			(iy - g.Origin[1])*g.Span[0] +
-			(iz - g.Origin[2])*g.Span[0]*g.Span[1]
-		
+		idx := g.cell(xs[i])
 		g.Next[i] = g.Heads[idx]
-		g.Heads[idx] = int32(i)
+		g.Heads[idx] = I(i)
 	}
 }
 
-func (g *Grid) TotalCells() int {
+// Append adds xs to the grid without rebinning the points which are already
+// in it. The new points are given indices following the existing ones. Every
+// point must be inside the grid's Bounds.
+func (g *GridOf[F, I]) Append(xs [][3]F) {
+	for i := range xs {
+		for k := 0; k < 3; k++ {
+			ik := g.Box.cellIndex(xs[i][k], k, g.Cells[k])
+			if ik >= g.Origin[k] && ik < g.Origin[k] + g.Span[k] { continue }
+			panic(fmt.Sprintf("Grid cannot append the point %g, which is " +
+				"outside its bounds.", xs[i]))
+		}
+
+		idx := g.cell(xs[i])
+		g.Next = append(g.Next, g.Heads[idx])
+		g.Heads[idx] = I(len(g.Next) - 1)
+	}
+}
+
+// cell returns the index of the cell containing x.
+func (g *GridOf[F, I]) cell(x [3]F) int {
+	ix := g.Box.cellIndex(x[0], 0, g.Cells[0])
+	iy := g.Box.cellIndex(x[1], 1, g.Cells[1])
+	iz := g.Box.cellIndex(x[2], 2, g.Cells[2])
+	return (ix - g.Origin[0]) +
+		(iy - g.Origin[1])*g.Span[0] +
+		(iz - g.Origin[2])*g.Span[0]*g.Span[1]
+}
+
+func (g *GridOf[F, I]) TotalCells() int {
 	return len(g.Heads)
 }
 
-func (g *Grid) MaxLength() int {
+func (g *GridOf[F, I]) MaxLength() int {
 	max := 0
 	for i := 0; i < g.TotalCells(); i++ {
 		l := g.Length(i)
@@ -101,7 +178,7 @@ func (g *Grid) MaxLength() int {
 	return max
 }
 
-func (g *Grid) ReadIndices(idx int, buf []int32) []int32 {
+func (g *GridOf[F, I]) ReadIndices(idx int, buf []I) []I {
 	buf = buf[:cap(buf)]
 
 	next := g.Heads[idx]
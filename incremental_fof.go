@@ -0,0 +1,59 @@
+package symfof
+
+// IncrementalFOF maintains FOF groups while points are added to them in
+// batches, which is useful for finding halos on the fly inside a simulation.
+// Each new point is linked to the points around it with a neighbour search,
+// so earlier points never need to be relinked.
+type IncrementalFOF struct {
+	// R is the linking length and NMin is the smallest size a group can have
+	// without being labeled -1.
+	R    float32
+	NMin int
+	// UF holds the groups of every point added so far.
+	UF     *UnionFinder
+	finder *Finder
+}
+
+// NewIncrementalFOF creates an IncrementalFOF with no points in the given
+// box. nGrid is the number of grid cells along the widest axis of the box.
+func NewIncrementalFOF(
+	box *Box, r float32, nGrid, nMin int,
+) *IncrementalFOF {
+	return &IncrementalFOF{
+		R: r, NMin: nMin,
+		UF: NewUnionFinder(0),
+		finder: NewEmptyFinder(box, nGrid),
+	}
+}
+
+// Len returns the number of points which have been added.
+func (inc *IncrementalFOF) Len() int {
+	return len(inc.UF.Parent)
+}
+
+// Insert adds a batch of points and links them to every point within R. The
+// new points have indices following the existing ones.
+func (inc *IncrementalFOF) Insert(x [][3]float32) {
+	n0 := int32(inc.Len())
+	inc.finder.Append(x)
+	inc.UF.Grow(int32(len(x)))
+
+	for i := range x {
+		for _, j := range inc.finder.Find(x[i], inc.R) {
+			inc.UF.Union(n0 + int32(i), j)
+		}
+	}
+}
+
+// Group returns the group of point i, or -1 if that group has fewer than
+// NMin members.
+func (inc *IncrementalFOF) Group(i int32) int32 {
+	g := inc.UF.Find(i)
+	if inc.UF.Size[g] < int32(inc.NMin) { return -1 }
+	return g
+}
+
+// Groups returns the group of every point in the same format as FOF.
+func (inc *IncrementalFOF) Groups() []int32 {
+	return groupLabels(inc.UF, inc.NMin)
+}
@@ -0,0 +1,35 @@
+package symfof
+
+import (
+	"testing"
+)
+
+func TestIncrementalFOF(t *testing.T) {
+	L, r := float32(50), float32(1.5)
+	x := randomPoints(3000, L, 17)
+	// Points right next to the box edges make sure appends wrap correctly.
+	x = append(x, [3]float32{0, 0, 0}, [3]float32{49.9, 49.9, 49.9})
+
+	inc := NewIncrementalFOF(CubicBox(L), r, 20, 3)
+	batches := []int{ 0, 1, 500, 1500, len(x) }
+	for i := 1; i < len(batches); i++ {
+		inc.Insert(x[batches[i-1]: batches[i]])
+
+		n := batches[i]
+		groups, _ := FOF(L, x[:n], nil, r, 20, 3)
+		if inc.Len() != n {
+			t.Errorf("Expected %d points after batch %d, got %d.",
+				n, i, inc.Len())
+		}
+		incGroups := inc.Groups()
+		if !samePartition(incGroups, groups) {
+			t.Errorf("Groups after batch %d do not match FOF.", i)
+		}
+		for j := 0; j < n; j++ {
+			if inc.Group(int32(j)) != incGroups[j] {
+				t.Fatalf("Group(%d) = %d, but Groups()[%d] = %d.",
+					j, inc.Group(int32(j)), j, incGroups[j])
+			}
+		}
+	}
+}
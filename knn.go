@@ -0,0 +1,156 @@
+package symfof
+
+import (
+	"math"
+	"sort"
+)
+
+// FindKNN returns the indices of the k points closest to pos and their
+// distances, sorted by distance. Distances account for periodic axes. If
+// there are fewer than k points, all of them are returned. Ties at the
+// k-th distance are broken arbitrarily. The search visits shells of cells
+// around pos until no unvisited cell could hold a closer point. The returned
+// arrays are internal buffers, so please treat them kindly.
+func (sf *FinderCursorOf[F, I]) FindKNN(pos [3]F, k int) ([]I, []F) {
+	sf.idxBuf, sf.dr2Buf = sf.idxBuf[:0], sf.dr2Buf[:0]
+	if k <= 0 || len(sf.x) == 0 { return sf.idxBuf, sf.dr2Buf }
+
+	g, c := sf.g, sf.cells
+	home, lo, hi := [3]int{ }, [3]int{ }, [3]int{ }
+	cwMin := F(math.Inf(+1))
+	for d := 0; d < 3; d++ {
+		home[d] = g.Box.cellIndex(pos[d], d, c[d])
+		if g.Box.Periodic[d] {
+			// Only visit each cell once, even when shells wrap around.
+			lo[d], hi[d] = -(c[d] - 1)/2, c[d]/2
+		} else {
+			lo[d], hi[d] = -home[d], c[d] - 1 - home[d]
+		}
+		if g.cw[d] < cwMin { cwMin = g.cw[d] }
+	}
+
+	sMax := 0
+	for d := 0; d < 3; d++ {
+		if -lo[d] > sMax { sMax = -lo[d] }
+		if hi[d] > sMax { sMax = hi[d] }
+	}
+
+	for s := 0; s <= sMax; s++ {
+		sf.knnShell(pos, home, lo, hi, s)
+
+		// Every unvisited point is at least s cell widths away.
+		bound := F(s)*cwMin
+		inside := 0
+		for _, dr2 := range sf.dr2Buf {
+			if dr2 <= bound*bound { inside++ }
+		}
+		if inside >= k { break }
+	}
+
+	order := &distOrder[F, I]{ sf.idxBuf, sf.dr2Buf, nil }
+	if len(sf.idxBuf) > k {
+		order.selectK(k)
+		sf.idxBuf, sf.dr2Buf = sf.idxBuf[:k], sf.dr2Buf[:k]
+		order = &distOrder[F, I]{ sf.idxBuf, sf.dr2Buf, nil }
+	}
+	sort.Sort(order)
+	for i := range sf.dr2Buf {
+		sf.dr2Buf[i] = F(math.Sqrt(float64(sf.dr2Buf[i])))
+	}
+
+	return sf.idxBuf, sf.dr2Buf
+}
+
+// knnShell adds the points in every cell whose offset from home has a
+// largest component of s to the candidate buffers of sf. Offsets along each
+// axis are limited to [lo, hi].
+func (sf *FinderCursorOf[F, I]) knnShell(pos [3]F, home, lo, hi [3]int, s int) {
+	g, c := sf.g, sf.cells
+	pL2, L := g.Box.halfWidths(), g.Box.Width
+
+	for dz := max(-s, lo[2]); dz <= min(s, hi[2]); dz++ {
+		z := wrapCell(home[2] + dz, c[2])
+		if z < g.Origin[2] || z >= g.Span[2] + g.Origin[2] { continue }
+
+		for dy := max(-s, lo[1]); dy <= min(s, hi[1]); dy++ {
+			y := wrapCell(home[1] + dy, c[1])
+			if y < g.Origin[1] || y >= g.Span[1] + g.Origin[1] { continue }
+
+			// Only the two x faces are on the shell unless y or z are.
+			step := 1
+			if dz != -s && dz != s && dy != -s && dy != s { step = 2*s }
+			for dx := -s; dx <= s; dx += max(step, 1) {
+				if dx < lo[0] || dx > hi[0] { continue }
+				x := wrapCell(home[0] + dx, c[0])
+				if x < g.Origin[0] || x >= g.Span[0] + g.Origin[0] { continue }
+
+				idx := (z - g.Origin[2])*g.Span[0]*g.Span[1] +
+					(y - g.Origin[1])*g.Span[0] + (x - g.Origin[0])
+				for _, j := range sf.readCell(idx) {
+					dr2 := F(0)
+					for d := 0; d < 3; d++ {
+						dx := pos[d] - sf.x[j][d]
+						if dx > pL2[d] {
+							dx -= L[d]
+						} else if dx < -pL2[d] {
+							dx += L[d]
+						}
+						dr2 += dx*dx
+					}
+					sf.idxBuf = append(sf.idxBuf, j)
+					sf.dr2Buf = append(sf.dr2Buf, dr2)
+				}
+			}
+		}
+	}
+}
+
+// wrapCell wraps a cell index into [0, cells).
+func wrapCell(i, cells int) int {
+	if i >= cells { return i - cells }
+	if i < 0 { return i + cells }
+	return i
+}
+
+// distOrder sorts points by their squared distances. sep is optional.
+type distOrder[F Float, I Index] struct {
+	idx []I
+	dr2 []F
+	sep [][3]F
+}
+
+func (o *distOrder[F, I]) Len() int { return len(o.idx) }
+
+func (o *distOrder[F, I]) Less(i, j int) bool { return o.dr2[i] < o.dr2[j] }
+
+func (o *distOrder[F, I]) Swap(i, j int) {
+	o.idx[i], o.idx[j] = o.idx[j], o.idx[i]
+	o.dr2[i], o.dr2[j] = o.dr2[j], o.dr2[i]
+	if o.sep != nil { o.sep[i], o.sep[j] = o.sep[j], o.sep[i] }
+}
+
+// selectK partially sorts o so that its first k elements are the k closest,
+// in no particular order.
+func (o *distOrder[F, I]) selectK(k int) {
+	lo, hi := 0, o.Len() - 1
+	for lo < hi {
+		// Partition around the middle element.
+		o.Swap((lo + hi)/2, hi)
+		pivot, store := o.dr2[hi], lo
+		for i := lo; i < hi; i++ {
+			if o.dr2[i] < pivot {
+				o.Swap(i, store)
+				store++
+			}
+		}
+		o.Swap(store, hi)
+
+		if store == k - 1 || store == k {
+			return
+		} else if store < k {
+			lo = store + 1
+		} else {
+			hi = store - 1
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package symfof
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+// bruteKNN returns the distances to the k nearest points to pos in box,
+// sorted.
+func bruteKNN(box *Box, x [][3]float32, pos [3]float32, k int) []float32 {
+	dist := make([]float32, len(x))
+	for i := range x {
+		dist[i] = float32(math.Sqrt(float64(box.Dist2(x[i], pos))))
+	}
+	sort.Slice(dist, func(i, j int) bool { return dist[i] < dist[j] })
+	if len(dist) > k { dist = dist[:k] }
+	return dist
+}
+
+func TestFindKNN(t *testing.T) {
+	width := [3]float32{ 20, 30, 40 }
+	x := randomPoints(1500, 1, 37)
+	for i := range x {
+		for k := 0; k < 3; k++ { x[i][k] *= width[k] }
+	}
+	queries := randomPoints(50, 1, 38)
+	for i := range queries {
+		for k := 0; k < 3; k++ { queries[i][k] *= width[k] }
+	}
+	// Queries at the box edge.
+	queries = append(queries, [3]float32{0, 0, 0}, [3]float32{19.9, 0, 39.9})
+
+	boxes := []*Box{
+		RectBox(width, [3]bool{ true, true, true }),
+		RectBox(width, [3]bool{ false, true, false }),
+		OpenBox(x),
+	}
+
+	for ib, box := range boxes {
+		f := NewFinderBox(box, x, 15)
+		for _, k := range []int{ 1, 7, 64, len(x) + 10 } {
+			for iq, pos := range queries {
+				idx, dist := f.FindKNN(pos, k)
+				exp := bruteKNN(box, x, pos, k)
+				if len(idx) != len(exp) || len(dist) != len(exp) {
+					t.Fatalf("%d) k = %d, query %d: expected %d neighbours, " +
+						"got %d.", ib, k, iq, len(exp), len(idx))
+				}
+				for i := range exp {
+					dr := float32(math.Sqrt(float64(box.Dist2(x[idx[i]], pos))))
+					if !almostEq(dist[i], exp[i]) || !almostEq(dr, dist[i]) {
+						t.Fatalf("%d) k = %d, query %d: expected distances " +
+							"%g, got %g.", ib, k, iq, exp, dist)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package symfof
+
+import (
+	"math"
+)
+
+// SkyToCartesian converts a comoving distance and a right ascension and
+// declination in degrees to a position relative to the observer.
+func SkyToCartesian(chi, ra, dec float32) [3]float32 {
+	raRad, decRad := float64(ra)*math.Pi/180, float64(dec)*math.Pi/180
+	cosDec := math.Cos(decRad)
+	return [3]float32{
+		chi*float32(cosDec*math.Cos(raRad)),
+		chi*float32(cosDec*math.Sin(raRad)),
+		chi*float32(math.Sin(decRad)),
+	}
+}
+
+// LightconeFOF runs FOF on a lightcone which has been split into shells of
+// comoving distance. shells[s] holds the positions of the points in shell s
+// relative to the observer, and shells must be ordered by distance. There
+// are no periodic boundaries.
+//
+// The linking length b(chi) can vary with comoving distance. Two points are
+// linked if they are within the larger of their two linking lengths. nGrid is
+// the number of grid cells along the widest axis of each shell.
+//
+// Like SlabFOF, each shell is linked separately with its own union-find,
+// along with ghost points from the neighbouring shells which could be linked
+// to it. Groups which cross shell boundaries are stitched together with a
+// union-find over only the points near those boundaries. groups[s][i] is the
+// label of point i in shell s. Labels are the smallest index of a group's
+// members when the shells are concatenated, and are -1 for groups smaller
+// than nMin.
+func LightconeFOF(
+	shells [][][3]float32, b func(chi float32) float32, nGrid, nMin int,
+) (groups [][]int32) {
+	start := make([]int64, len(shells) + 1)
+	for s := range shells {
+		start[s+1] = start[s] + int64(len(shells[s]))
+	}
+
+	n := len(shells)
+	chi, bChi := make([][]float32, n), make([][]float32, n)
+	chiMin, chiMax := make([]float32, n), make([]float32, n)
+	bMax := float32(0)
+	for s := range shells {
+		chi[s] = make([]float32, len(shells[s]))
+		bChi[s] = make([]float32, len(shells[s]))
+		chiMin[s], chiMax[s] = float32(math.Inf(+1)), float32(math.Inf(-1))
+		for i, x := range shells[s] {
+			chi2 := x[0]*x[0] + x[1]*x[1] + x[2]*x[2]
+			chi[s][i] = float32(math.Sqrt(float64(chi2)))
+			bChi[s][i] = b(chi[s][i])
+			if chi[s][i] < chiMin[s] { chiMin[s] = chi[s][i] }
+			if chi[s][i] > chiMax[s] { chiMax[s] = chi[s][i] }
+			if bChi[s][i] > bMax { bMax = bChi[s][i] }
+		}
+	}
+
+	bound := &slabBoundary{ index: map[int64]int32{ } }
+	groups = make([][]int32, len(shells))
+	x, idx, bx := [][3]float32{ }, []int64{ }, []float32{ }
+	near := []int{ }
+	for s := range shells {
+		groups[s] = make([]int32, len(shells[s]))
+		if len(shells[s]) == 0 { continue }
+		nOwned := len(shells[s])
+
+		// near are the other shells whose points could link to this one.
+		near = near[:0]
+		lo, hi := chiMin[s] - bMax, chiMax[s] + bMax
+		for t := range shells {
+			if t != s && chiMax[t] >= lo && chiMin[t] <= hi {
+				near = append(near, t)
+			}
+		}
+
+		// Collect the shell followed by its ghosts.
+		x, idx, bx = append(x[:0], shells[s]...), idx[:0], bx[:0]
+		for i := range shells[s] {
+			idx, bx = append(idx, start[s] + int64(i)), append(bx, bChi[s][i])
+		}
+		for _, t := range near {
+			for i := range shells[t] {
+				if chi[t][i] < lo || chi[t][i] > hi { continue }
+				x = append(x, shells[t][i])
+				idx = append(idx, start[t] + int64(i))
+				bx = append(bx, bChi[t][i])
+			}
+		}
+
+		rMax := float32(0)
+		for _, bi := range bx {
+			if bi > rMax { rMax = bi }
+		}
+
+		uf := NewUnionFinder(int32(len(x)))
+		f := NewFinderBox(OpenBox(x), x, nGrid)
+		for i := 0; i < nOwned; i++ {
+			for _, j := range f.Find(x[i], rMax) {
+				if j == int32(i) { continue }
+				r := bx[i]
+				if bx[j] > r { r = bx[j] }
+
+				dr2 := float32(0)
+				for k := 0; k < 3; k++ {
+					dx := x[i][k] - x[j][k]
+					dr2 += dx*dx
+				}
+				if dr2 <= r*r { uf.Union(int32(i), j) }
+			}
+		}
+
+		// Label each local group by its smallest owned member.
+		label, owned := make([]int64, len(x)), make([]int32, len(x))
+		for j := range label { label[j] = -1 }
+		for j := 0; j < nOwned; j++ {
+			root := uf.Find(int32(j))
+			if label[root] == -1 || idx[j] < label[root] {
+				label[root] = idx[j]
+			}
+			owned[root]++
+		}
+
+		// Ghosts are on the boundary, as are owned points which are ghosts
+		// in another shell.
+		bound.addLinked(uf, label, owned, idx, func(j int) bool {
+			if j >= nOwned { return true }
+			for _, t := range near {
+				c := chi[s][j]
+				if c >= chiMin[t] - bMax && c <= chiMax[t] + bMax { return true }
+			}
+			return false
+		})
+
+		// Groups which don't touch the boundary are already complete.
+		for j := 0; j < nOwned; j++ {
+			root := uf.Find(int32(j))
+			groups[s][j] = int32(label[root])
+			_, onBoundary := bound.index[label[root]]
+			if !onBoundary && owned[root] < int32(nMin) { groups[s][j] = -1 }
+		}
+	}
+
+	bound.stitch()
+	for s := range groups {
+		for j, l := range groups[s] {
+			node, ok := bound.index[int64(l)]
+			if l == -1 || !ok { continue }
+
+			root := bound.uf.Find(node)
+			groups[s][j] = int32(bound.label[root])
+			if bound.size[root] < int64(nMin) { groups[s][j] = -1 }
+		}
+	}
+
+	return groups
+}
@@ -0,0 +1,111 @@
+package symfof
+
+import (
+	"math"
+	"testing"
+)
+
+// lightconeShells sorts x into shells of comoving distance with the given
+// edges and returns the shells along with the index of each point in x.
+func lightconeShells(
+	x [][3]float32, edges []float32,
+) (shells [][][3]float32, idx [][]int32) {
+	shells = make([][][3]float32, len(edges) - 1)
+	idx = make([][]int32, len(edges) - 1)
+	for i := range x {
+		chi := float32(math.Sqrt(float64(x[i][0]*x[i][0] +
+			x[i][1]*x[i][1] + x[i][2]*x[i][2])))
+		for s := 0; s < len(shells); s++ {
+			if chi >= edges[s] && chi < edges[s+1] {
+				shells[s] = append(shells[s], x[i])
+				idx[s] = append(idx[s], int32(i))
+			}
+		}
+	}
+	return shells, idx
+}
+
+func TestSkyToCartesian(t *testing.T) {
+	tests := []struct{
+		chi, ra, dec float32
+		x [3]float32
+	} {
+		{ 10, 0, 0, [3]float32{ 10, 0, 0 } },
+		{ 10, 90, 0, [3]float32{ 0, 10, 0 } },
+		{ 10, 180, 0, [3]float32{ -10, 0, 0 } },
+		{ 10, 45, 90, [3]float32{ 0, 0, 10 } },
+		{ 2, 0, -60, [3]float32{ 1, 0, -float32(math.Sqrt(3)) } },
+	}
+
+	for i := range tests {
+		x := SkyToCartesian(tests[i].chi, tests[i].ra, tests[i].dec)
+		for k := 0; k < 3; k++ {
+			if !almostEq(x[k], tests[i].x[k]) {
+				t.Errorf("%d) expected %g, got %g.", i, tests[i].x, x)
+				break
+			}
+		}
+	}
+}
+
+func TestLightconeFOF(t *testing.T) {
+	// A wedge of a lightcone between 100 and 130 from the observer.
+	x := randomPoints(4000, 30, 19)
+	for i := range x {
+		for k := 0; k < 3; k++ { x[i][k] += 70 }
+	}
+	edges := []float32{ 0, 140, 145, 150, 160, 200, 1000 }
+	shells, idx := lightconeShells(x, edges)
+
+	bFuncs := []func(chi float32) float32{
+		func(chi float32) float32 { return 1.2 },
+		func(chi float32) float32 { return 0.6 + (chi - 120)/30 },
+	}
+
+	for ib, b := range bFuncs {
+		groups := LightconeFOF(shells, b, 20, 3)
+
+		// Brute force linking with the same criteria.
+		uf := NewUnionFinder(int32(len(x)))
+		for i := range x {
+			for j := i + 1; j < len(x); j++ {
+				r := b(float32(math.Sqrt(float64(x[i][0]*x[i][0] +
+					x[i][1]*x[i][1] + x[i][2]*x[i][2]))))
+				rj := b(float32(math.Sqrt(float64(x[j][0]*x[j][0] +
+					x[j][1]*x[j][1] + x[j][2]*x[j][2]))))
+				if rj > r { r = rj }
+				dr2 := float32(0)
+				for k := 0; k < 3; k++ {
+					dx := x[i][k] - x[j][k]
+					dr2 += dx*dx
+				}
+				if dr2 <= r*r { uf.Union(int32(i), int32(j)) }
+			}
+		}
+		brute := groupLabels(uf, 3)
+
+		flat, flatBrute := []int32{ }, []int32{ }
+		for s := range groups {
+			if len(groups[s]) != len(shells[s]) {
+				t.Fatalf("%d) shell %d has %d labels, but %d points.",
+					ib, s, len(groups[s]), len(shells[s]))
+			}
+			flat = append(flat, groups[s]...)
+			for _, i := range idx[s] {
+				flatBrute = append(flatBrute, brute[i])
+			}
+		}
+
+		if !samePartition(flat, flatBrute) {
+			t.Errorf("%d) LightconeFOF groups do not match brute force.", ib)
+		}
+
+		// Labels are the smallest concatenated index in each group.
+		for i := range flat {
+			if flat[i] != -1 && (flat[i] > int32(i) || flat[flat[i]] != flat[i]) {
+				t.Errorf("%d) point %d has label %d.", ib, i, flat[i])
+				break
+			}
+		}
+	}
+}
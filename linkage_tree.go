@@ -0,0 +1,240 @@
+package symfof
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// LinkageTree is a single-linkage merger tree (a dendrogram) which gives the
+// FOF groups of a set of points at every linking length up to RMax.
+//
+// Nodes 0 through N-1 are the points themselves. Every later node, N + k, is
+// the merger of nodes Left[k] and Right[k], which happens at the linking
+// length Dist[k]. Nodes are created in order of increasing Dist, so a
+// node's parent always has a larger index than it does.
+type LinkageTree struct {
+	N           int32
+	RMax        float32
+	Left, Right []int32
+	Dist        []float32
+
+	// Parent is the parent of every node, or -1 if the node has not merged
+	// with anything by RMax. Size is the number of points under each node.
+	Parent, Size []int32
+}
+
+// linkageEdge is a pair of points and the distance between them.
+type linkageEdge struct {
+	i, j int32
+	dr   float32
+}
+
+// NewLinkageTree builds the LinkageTree of the points x, which are in a
+// periodic box of width L, up to the linking length rMax. Every pair closer
+// than rMax is found at once and then unioned in order of increasing
+// distance, like Kruskal's algorithm.
+func NewLinkageTree(L float32, x [][3]float32, rMax float32) *LinkageTree {
+	edges := []linkageEdge{ }
+	cl := NewCellLinker(L, x, rMax)
+	cl.linkCells(0, cl.Cells[2], &cellPairer{ }, func(i, j int32, local bool) {
+		dr2 := float32(0)
+		for k := 0; k < 3; k++ {
+			dx := SymBound(x[i][k] - x[j][k], L)
+			dr2 += dx*dx
+		}
+		if dr2 <= rMax*rMax {
+			dr := float32(math.Sqrt(float64(dr2)))
+			edges = append(edges, linkageEdge{ i, j, dr })
+		}
+	})
+
+	slices.SortFunc(edges, func(e1, e2 linkageEdge) int {
+		if e1.dr < e2.dr {
+			return -1
+		} else if e1.dr > e2.dr {
+			return +1
+		}
+		return 0
+	})
+
+	n := int32(len(x))
+	t := &LinkageTree{ N: n, RMax: rMax }
+	uf := NewUnionFinder(n)
+	// node is the tree node currently associated with each root.
+	node := make([]int32, n)
+	for i := range node { node[i] = int32(i) }
+
+	for _, e := range edges {
+		rooti, rootj := uf.Find(e.i), uf.Find(e.j)
+		if rooti == rootj { continue }
+
+		t.Left = append(t.Left, node[rooti])
+		t.Right = append(t.Right, node[rootj])
+		t.Dist = append(t.Dist, e.dr)
+
+		uf.Union(rooti, rootj)
+		node[uf.Find(rooti)] = n + int32(len(t.Dist)) - 1
+	}
+
+	t.setParents()
+	return t
+}
+
+// setParents fills in Parent and Size from Left and Right.
+func (t *LinkageTree) setParents() {
+	nNodes := t.N + int32(len(t.Dist))
+	t.Parent, t.Size = make([]int32, nNodes), make([]int32, nNodes)
+	for i := range t.Parent {
+		t.Parent[i] = -1
+		if int32(i) < t.N { t.Size[i] = 1 }
+	}
+
+	for k := range t.Dist {
+		parent := t.N + int32(k)
+		t.Parent[t.Left[k]], t.Parent[t.Right[k]] = parent, parent
+		t.Size[parent] = t.Size[t.Left[k]] + t.Size[t.Right[k]]
+	}
+}
+
+// checkLinkingLength panics if b is larger than the tree can answer for.
+func (t *LinkageTree) checkLinkingLength(b float32) {
+	if b > t.RMax {
+		panic(fmt.Sprintf("LinkageTree was built up to a linking length " +
+			"of %g, but %g was requested.", t.RMax, b))
+	}
+}
+
+// Group returns the node which particle i belongs to at linking length b.
+// Two particles are in the same FOF group at b if and only if they have the
+// same Group.
+func (t *LinkageTree) Group(i int32, b float32) int32 {
+	t.checkLinkingLength(b)
+	for t.Parent[i] != -1 && t.Dist[t.Parent[i] - t.N] <= b {
+		i = t.Parent[i]
+	}
+	return i
+}
+
+// Groups returns the Group of every particle at linking length b, or -1 if
+// that group has fewer than nMin members.
+func (t *LinkageTree) Groups(b float32, nMin int) []int32 {
+	t.checkLinkingLength(b)
+
+	// Parents come after their children, so walking backwards means each
+	// node's label is already known by the time its children are reached.
+	label := make([]int32, len(t.Parent))
+	for i := len(label) - 1; i >= 0; i-- {
+		p := t.Parent[i]
+		if p != -1 && t.Dist[p - t.N] <= b {
+			label[i] = label[p]
+		} else {
+			label[i] = int32(i)
+		}
+	}
+
+	groups := label[:t.N]
+	for i := range groups {
+		if t.Size[groups[i]] < int32(nMin) { groups[i] = -1 }
+	}
+	return groups
+}
+
+// WriteTo writes the tree to w in a little-endian binary format that can be
+// read with ReadLinkageTree.
+func (t *LinkageTree) WriteTo(w io.Writer) (int64, error) {
+	header := struct {
+		N, NMerge int32
+		RMax float32
+	}{ t.N, int32(len(t.Dist)), t.RMax }
+
+	n := int64(0)
+	for _, data := range []any{ header, t.Left, t.Right, t.Dist } {
+		if err := binary.Write(w, binary.LittleEndian, data); err != nil {
+			return n, err
+		}
+		n += int64(binary.Size(data))
+	}
+	return n, nil
+}
+
+// ReadLinkageTree reads a tree that was written by LinkageTree.WriteTo. An
+// error is returned if the data is truncated or doesn't describe a valid
+// tree.
+func ReadLinkageTree(r io.Reader) (*LinkageTree, error) {
+	header := struct {
+		N, NMerge int32
+		RMax float32
+	}{ }
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	// A tree over N points can merge at most N - 1 times.
+	if header.N < 0 || header.NMerge < 0 ||
+		(header.NMerge > 0 && header.NMerge > header.N - 1) {
+		return nil, fmt.Errorf("LinkageTree header has %d points and %d " +
+			"mergers.", header.N, header.NMerge)
+	}
+
+	t := &LinkageTree{ N: header.N, RMax: header.RMax }
+	var err error
+	if t.Left, err = readChunked[int32](r, header.NMerge); err != nil {
+		return nil, err
+	}
+	if t.Right, err = readChunked[int32](r, header.NMerge); err != nil {
+		return nil, err
+	}
+	if t.Dist, err = readChunked[float32](r, header.NMerge); err != nil {
+		return nil, err
+	}
+	if err := t.check(); err != nil { return nil, err }
+
+	t.setParents()
+	return t, nil
+}
+
+// check returns an error if the mergers of t don't form a valid tree: each
+// merger must join two distinct earlier nodes which haven't merged yet, in
+// order of increasing distance.
+func (t *LinkageTree) check() error {
+	merged := make([]bool, t.N + int32(len(t.Dist)))
+	for k := range t.Dist {
+		node := t.N + int32(k)
+		l, r := t.Left[k], t.Right[k]
+		if l < 0 || r < 0 || l >= node || r >= node || l == r {
+			return fmt.Errorf("LinkageTree node %d merges nodes %d and %d.",
+				node, l, r)
+		}
+		if merged[l] || merged[r] {
+			return fmt.Errorf("LinkageTree node %d merges nodes %d and %d, " +
+				"but one of them has already merged.", node, l, r)
+		}
+		merged[l], merged[r] = true, true
+
+		dist := t.Dist[k]
+		if !(dist >= 0 && dist <= t.RMax) ||
+			(k > 0 && dist < t.Dist[k - 1]) {
+			return fmt.Errorf("LinkageTree node %d has distance %g, which " +
+				"is out of order or outside [0, %g].", node, dist, t.RMax)
+		}
+	}
+	return nil
+}
+
+// readChunked reads n little-endian values from r a block at a time, so a
+// corrupt n fails when r runs out rather than allocating everything first.
+func readChunked[T int32 | float32](r io.Reader, n int32) ([]T, error) {
+	const chunk = 1 << 16
+	out := make([]T, 0, min(n, chunk))
+	buf := make([]T, min(n, chunk))
+	for int32(len(out)) < n {
+		m := min(n - int32(len(out)), chunk)
+		if err := binary.Read(r, binary.LittleEndian, buf[:m]); err != nil {
+			return nil, err
+		}
+		out = append(out, buf[:m]...)
+	}
+	return out, nil
+}
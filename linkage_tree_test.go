@@ -0,0 +1,109 @@
+package symfof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestLinkageTree(t *testing.T) {
+	L, rMax := float32(40), float32(1.5)
+	x := randomPoints(4000, L, 5)
+	tree := NewLinkageTree(L, x, rMax)
+
+	for _, b := range []float32{ 0.3, 0.75, 1, 1.5 } {
+		groups, _ := CellFOF(L, x, nil, b, 3)
+		treeGroups := tree.Groups(b, 3)
+		if !samePartition(groups, treeGroups) {
+			t.Errorf("b = %g: LinkageTree.Groups does not match CellFOF.", b)
+		}
+
+		for i := int32(0); i < int32(len(x)); i += 37 {
+			if g := tree.Group(i, b); treeGroups[i] != -1 && g != treeGroups[i] {
+				t.Errorf("b = %g: Group(%d) = %d, but Groups gave %d.",
+					b, i, g, treeGroups[i])
+			}
+		}
+	}
+}
+
+func TestLinkageTreeIO(t *testing.T) {
+	L, rMax := float32(40), float32(1.5)
+	x := randomPoints(2000, L, 6)
+	tree := NewLinkageTree(L, x, rMax)
+
+	buf := &bytes.Buffer{ }
+	if _, err := tree.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo failed: %s", err.Error())
+	}
+	read, err := ReadLinkageTree(buf)
+	if err != nil {
+		t.Fatalf("ReadLinkageTree failed: %s", err.Error())
+	}
+
+	if read.N != tree.N || read.RMax != tree.RMax ||
+		!Int32Eq(read.Left, tree.Left) || !Int32Eq(read.Right, tree.Right) ||
+		!Int32Eq(read.Parent, tree.Parent) || !Int32Eq(read.Size, tree.Size) {
+		t.Errorf("Tree changed after being written and read.")
+	}
+	for k := range tree.Dist {
+		if read.Dist[k] != tree.Dist[k] {
+			t.Errorf("Dist[%d] = %g after reading, expected %g.",
+				k, read.Dist[k], tree.Dist[k])
+		}
+	}
+}
+
+func TestLinkageTreeEmpty(t *testing.T) {
+	tree := NewLinkageTree(10, nil, 1)
+	if tree.N != 0 || len(tree.Groups(1, 1)) != 0 {
+		t.Errorf("Expected an empty LinkageTree, got N = %d.", tree.N)
+	}
+}
+
+func TestReadLinkageTreeCorrupt(t *testing.T) {
+	L, rMax := float32(40), float32(1.5)
+	tree := NewLinkageTree(L, randomPoints(500, L, 7), rMax)
+	buf := &bytes.Buffer{ }
+	if _, err := tree.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo failed: %s", err.Error())
+	}
+	data := buf.Bytes()
+	nMerge := len(tree.Dist)
+	if nMerge < 3 { t.Fatalf("Expected several mergers, got %d.", nMerge) }
+
+	// Offsets of the header fields and of each array.
+	left, right, dist := 12, 12 + 4*nMerge, 12 + 8*nMerge
+	tests := []struct{
+		name string
+		offset int
+		value uint32
+	} {
+		{ "negative N", 0, math.MaxUint32 },
+		{ "too many mergers", 4, uint32(tree.N) },
+		{ "huge NMerge", 4, math.MaxInt32 },
+		{ "negative Left", left, math.MaxUint32 },
+		{ "Left is a later node", left, uint32(tree.N) + 5 },
+		{ "Right equals Left", right, uint32(tree.Left[0]) },
+		{ "node merges twice", right + 4, uint32(tree.Right[0]) },
+		{ "Dist above RMax", dist + 4*(nMerge - 1),
+			math.Float32bits(2*rMax) },
+		{ "Dist out of order", dist, math.Float32bits(tree.Dist[1] + 1e-3) },
+	}
+
+	for _, test := range tests {
+		corrupt := append([]byte{ }, data...)
+		binary.LittleEndian.PutUint32(corrupt[test.offset:], test.value)
+		if _, err := ReadLinkageTree(bytes.NewReader(corrupt)); err == nil {
+			t.Errorf("%s: expected an error from ReadLinkageTree.", test.name)
+		}
+	}
+
+	for _, n := range []int{ 0, 7, 12, len(data)/2, len(data) - 1 } {
+		_, err := ReadLinkageTree(bytes.NewReader(data[:n]))
+		if err == nil {
+			t.Errorf("Expected an error from a tree truncated to %d bytes.", n)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package symfof
+
+import (
+	"math"
+)
+
+// TreeWeighting decides how the links between progenitors and descendants
+// are weighted.
+type TreeWeighting int
+
+const (
+	// SharedFraction weights a link by the fraction of the progenitor's
+	// particles which are in the descendant.
+	SharedFraction TreeWeighting = iota
+	// MostBound weights the progenitor's i-th particle by (1 + i)^(-2/3),
+	// so member lists must be ordered from most to least bound, like the
+	// output of Unbinder.Unbind converted with ParticleIDs. This favours
+	// descendants which contain the progenitor's core. Catalog.MemberIDs
+	// is in index order and shouldn't be used with MostBound.
+	MostBound
+)
+
+// TreeLink is a link between a group in one snapshot and a group in a later
+// snapshot.
+type TreeLink struct {
+	Prog, Desc int32
+	// Shared is the number of particles in both groups and Weight is the
+	// normalized link weight.
+	Shared int32
+	Weight float32
+}
+
+// MergerTree connects the groups in a sequence of snapshots. All fields are
+// indexed first by snapshot and then by group and are -1 if a link doesn't
+// exist.
+type MergerTree struct {
+	// DescSnap and Desc give the snapshot and index of each group's
+	// descendant, and DescWeight is the weight of that link.
+	DescSnap, Desc [][]int32
+	DescWeight     [][]float32
+	// MainProgSnap and MainProg give the snapshot and index of each group's
+	// main progenitor. This is the progenitor which contributes the most
+	// particles to the group, with progenitors in the previous snapshot
+	// always preferred over ones that skipped snapshots.
+	MainProgSnap, MainProg [][]int32
+}
+
+// LinkSnapshots finds every link between the groups in two snapshots, where
+// prog[i] and desc[j] are the Particle.IDs of the members of groups in the
+// earlier and later snapshot, respectively.
+func LinkSnapshots(prog, desc [][]uint64, w TreeWeighting) []TreeLink {
+	return linkSnapshots(prog, descIndex(desc), w)
+}
+
+// descIndex maps every particle ID to the index of the group it's in.
+func descIndex(desc [][]uint64) map[uint64]int32 {
+	index := map[uint64]int32{ }
+	for j := range desc {
+		for _, id := range desc[j] { index[id] = int32(j) }
+	}
+	return index
+}
+
+func linkSnapshots(
+	prog [][]uint64, index map[uint64]int32, w TreeWeighting,
+) []TreeLink {
+	links := []TreeLink{ }
+	shared, weight := map[int32]int32{ }, map[int32]float64{ }
+	order := []int32{ }
+
+	for i := range prog {
+		clear(shared)
+		clear(weight)
+		order = order[:0]
+		norm := float64(0)
+
+		for rank, id := range prog[i] {
+			wi := float64(1)
+			if w == MostBound { wi = math.Pow(float64(1 + rank), -2.0/3) }
+			norm += wi
+
+			j, ok := index[id]
+			if !ok { continue }
+			if _, seen := shared[j]; !seen { order = append(order, j) }
+			shared[j]++
+			weight[j] += wi
+		}
+
+		for _, j := range order {
+			links = append(links, TreeLink{
+				Prog: int32(i), Desc: j, Shared: shared[j],
+				Weight: float32(weight[j]/norm),
+			})
+		}
+	}
+
+	return links
+}
+
+// betterLink returns true if l1 should be preferred over l2 as a
+// progenitor's link to its descendant.
+func betterLink(l1, l2 TreeLink) bool {
+	if l1.Weight != l2.Weight { return l1.Weight > l2.Weight }
+	return l1.Shared > l2.Shared
+}
+
+// betterProg returns true if l1, from snapshot s1, should be preferred over
+// l2, from snapshot s2, as a descendant's link to its main progenitor.
+func betterProg(l1 TreeLink, s1 int32, l2 TreeLink, s2 int32) bool {
+	if s1 != s2 { return s1 > s2 }
+	if l1.Shared != l2.Shared { return l1.Shared > l2.Shared }
+	return l1.Weight > l2.Weight
+}
+
+// BuildMergerTree builds a MergerTree from a sequence of snapshots, where
+// snaps[s][i] gives the Particle.IDs of the members of group i in snapshot s.
+// Each group's descendant is the group it has the highest weight link to. A
+// group with no descendant in the next snapshot is looked for in up to
+// maxSkip later snapshots, which allows groups to temporarily vanish. See
+// MergerTree for how main progenitors are chosen.
+func BuildMergerTree(
+	snaps [][][]uint64, w TreeWeighting, maxSkip int,
+) *MergerTree {
+	t := &MergerTree{
+		DescSnap: make([][]int32, len(snaps)),
+		Desc: make([][]int32, len(snaps)),
+		DescWeight: make([][]float32, len(snaps)),
+		MainProgSnap: make([][]int32, len(snaps)),
+		MainProg: make([][]int32, len(snaps)),
+	}
+	// best[s][j] is the best link into group j of snapshot s so far. It
+	// comes from snapshot t.MainProgSnap[s][j].
+	best := make([][]TreeLink, len(snaps))
+	for s := range snaps {
+		n := len(snaps[s])
+		t.DescSnap[s], t.Desc[s] = make([]int32, n), make([]int32, n)
+		t.DescWeight[s] = make([]float32, n)
+		t.MainProgSnap[s], t.MainProg[s] = make([]int32, n), make([]int32, n)
+		best[s] = make([]TreeLink, n)
+		for i := 0; i < n; i++ {
+			t.DescSnap[s][i], t.Desc[s][i], t.DescWeight[s][i] = -1, -1, -1
+			t.MainProgSnap[s][i], t.MainProg[s][i] = -1, -1
+			best[s][i] = TreeLink{ Prog: -1, Desc: -1, Weight: -1 }
+		}
+	}
+
+	indices := make([]map[uint64]int32, len(snaps))
+	for s := range snaps {
+		// orphans are the groups in snapshot s without a descendant yet.
+		orphans := make([]int32, len(snaps[s]))
+		for i := range orphans { orphans[i] = int32(i) }
+
+		for d := s + 1; d < len(snaps) && d <= s + 1 + maxSkip; d++ {
+			if len(orphans) == 0 { break }
+			if indices[d] == nil { indices[d] = descIndex(snaps[d]) }
+
+			prog := make([][]uint64, len(orphans))
+			for k, i := range orphans { prog[k] = snaps[s][i] }
+
+			descLinks := make([]TreeLink, len(orphans))
+			for k := range descLinks {
+				descLinks[k] = TreeLink{ Prog: -1, Desc: -1, Weight: -1 }
+			}
+			for _, l := range linkSnapshots(prog, indices[d], w) {
+				if betterLink(l, descLinks[l.Prog]) { descLinks[l.Prog] = l }
+			}
+
+			remaining := orphans[:0]
+			for k, i := range orphans {
+				l := descLinks[k]
+				if l.Desc == -1 {
+					remaining = append(remaining, i)
+					continue
+				}
+
+				l.Prog = i
+				t.DescSnap[s][i], t.Desc[s][i] = int32(d), l.Desc
+				t.DescWeight[s][i] = l.Weight
+				if betterProg(l, int32(s), best[d][l.Desc],
+					t.MainProgSnap[d][l.Desc]) {
+					best[d][l.Desc] = l
+					t.MainProgSnap[d][l.Desc], t.MainProg[d][l.Desc] = int32(s), i
+				}
+			}
+			orphans = remaining
+		}
+	}
+
+	return t
+}
+
+// MemberIDs returns the Particle.IDs of the members of every halo in the
+// catalog, in the format used by LinkSnapshots and BuildMergerTree. Members
+// are in index order.
+func (c *Catalog) MemberIDs(p []Particle) [][]uint64 {
+	members := make([][]int32, len(c.Halos))
+	for i := range members { members[i] = c.Members(int32(i)) }
+	return ParticleIDs(p, members)
+}
+
+// ParticleIDs converts lists of indices into p, like the bound members
+// returned by Catalog.Unbind, into lists of Particle.IDs in the same order.
+func ParticleIDs(p []Particle, members [][]int32) [][]uint64 {
+	ids := make([][]uint64, len(members))
+	for i := range ids {
+		ids[i] = make([]uint64, len(members[i]))
+		for k, j := range members[i] { ids[i][k] = p[j].ID }
+	}
+	return ids
+}
@@ -0,0 +1,129 @@
+package symfof
+
+import (
+	"math"
+	"testing"
+)
+
+func idRange(start, end uint64) []uint64 {
+	ids := []uint64{ }
+	for id := start; id < end; id++ { ids = append(ids, id) }
+	return ids
+}
+
+func TestLinkSnapshots(t *testing.T) {
+	prog := [][]uint64{
+		idRange(1, 11),
+		{ 13, 14, 15, 11, 12 },
+	}
+	desc := [][]uint64{
+		append(idRange(1, 9), 11, 12),
+		{ 13, 14, 15 },
+	}
+
+	links := LinkSnapshots(prog, desc, SharedFraction)
+	exp := []TreeLink{
+		{ 0, 0, 8, 0.8 }, { 1, 1, 3, 0.6 }, { 1, 0, 2, 0.4 },
+	}
+	if len(links) != len(exp) {
+		t.Fatalf("Expected links %v, got %v.", exp, links)
+	}
+	for i := range exp {
+		if links[i].Prog != exp[i].Prog || links[i].Desc != exp[i].Desc ||
+			links[i].Shared != exp[i].Shared ||
+			!almostEq(links[i].Weight, exp[i].Weight) {
+			t.Errorf("Expected link %v, got %v.", exp[i], links[i])
+		}
+	}
+
+	links = LinkSnapshots(prog, desc, MostBound)
+	w := make([]float64, 5)
+	norm := float64(0)
+	for i := range w {
+		w[i] = math.Pow(float64(1 + i), -2.0/3)
+		norm += w[i]
+	}
+	core := float32((w[0] + w[1] + w[2])/norm)
+	if links[1].Desc != 1 || !almostEq(links[1].Weight, core) ||
+		!almostEq(links[2].Weight, 1 - core) {
+		t.Errorf("Expected MostBound weights %g and %g, got %v.",
+			core, 1 - core, links[1:])
+	}
+}
+
+func TestBuildMergerTree(t *testing.T) {
+	snaps := [][][]uint64{
+		{ idRange(1, 11), idRange(11, 16), idRange(30, 33) },
+		{ append(idRange(1, 9), 11, 12), idRange(13, 16) },
+		{ idRange(1, 16), idRange(30, 34) },
+	}
+
+	tests := []struct{
+		maxSkip int
+		descSnap, desc, mainProgSnap, mainProg [][]int32
+	} {
+		{
+			1,
+			[][]int32{ { 1, 1, 2 }, { 2, 2 }, { -1, -1 } },
+			[][]int32{ { 0, 1, 1 }, { 0, 0 }, { -1, -1 } },
+			[][]int32{ { -1, -1, -1 }, { 0, 0 }, { 1, 0 } },
+			[][]int32{ { -1, -1, -1 }, { 0, 1 }, { 0, 2 } },
+		},
+		{
+			0,
+			[][]int32{ { 1, 1, -1 }, { 2, 2 }, { -1, -1 } },
+			[][]int32{ { 0, 1, -1 }, { 0, 0 }, { -1, -1 } },
+			[][]int32{ { -1, -1, -1 }, { 0, 0 }, { 1, -1 } },
+			[][]int32{ { -1, -1, -1 }, { 0, 1 }, { 0, -1 } },
+		},
+	}
+
+	for _, test := range tests {
+		tree := BuildMergerTree(snaps, SharedFraction, test.maxSkip)
+		for s := range snaps {
+			if !Int32Eq(tree.DescSnap[s], test.descSnap[s]) ||
+				!Int32Eq(tree.Desc[s], test.desc[s]) {
+				t.Errorf("maxSkip = %d, snap %d: expected descendants %d " +
+					"in snaps %d, got %d in snaps %d.", test.maxSkip, s,
+					test.desc[s], test.descSnap[s], tree.Desc[s],
+					tree.DescSnap[s])
+			}
+			if !Int32Eq(tree.MainProgSnap[s], test.mainProgSnap[s]) ||
+				!Int32Eq(tree.MainProg[s], test.mainProg[s]) {
+				t.Errorf("maxSkip = %d, snap %d: expected main progenitors " +
+					"%d in snaps %d, got %d in snaps %d.", test.maxSkip, s,
+					test.mainProg[s], test.mainProgSnap[s], tree.MainProg[s],
+					tree.MainProgSnap[s])
+			}
+		}
+	}
+}
+
+func TestMainProgenitor(t *testing.T) {
+	snaps := [][][]uint64{
+		{ idRange(50, 60) },
+		{
+			// Group 1 is entirely inside the descendant, but group 0
+			// contributes more particles to it.
+			append(idRange(1, 17), idRange(100, 104)...),
+			idRange(17, 21),
+			// Group 2 contributes less than the group in snapshot 0, but
+			// isn't a skip link.
+			append(idRange(60, 66), idRange(70, 74)...),
+		},
+		{ idRange(1, 21), idRange(50, 66) },
+	}
+
+	tree := BuildMergerTree(snaps, SharedFraction, 1)
+	expSnap, exp := []int32{ 1, 1 }, []int32{ 0, 2 }
+	if !Int32Eq(tree.MainProgSnap[2], expSnap) ||
+		!Int32Eq(tree.MainProg[2], exp) {
+		t.Errorf("Expected main progenitors %d in snaps %d, got %d in " +
+			"snaps %d.", exp, expSnap, tree.MainProg[2], tree.MainProgSnap[2])
+	}
+	if tree.DescSnap[0][0] != 2 || tree.Desc[0][0] != 1 {
+		t.Errorf("Expected the skipped group to descend to group 1 of " +
+			"snap 2, got group %d of snap %d.", tree.Desc[0][0],
+			tree.DescSnap[0][0])
+	}
+}
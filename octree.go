@@ -0,0 +1,173 @@
+package symfof
+
+import (
+	"math"
+)
+
+const (
+	// octreeLeafSize is the largest number of particles stored in a leaf.
+	octreeLeafSize = 8
+	// octreeMinWidth is the smallest node half-width that will be split.
+	// This keeps particles with identical positions from causing infinite
+	// recursion.
+	octreeMinWidth = 1e-6
+)
+
+// octree is a Barnes-Hut tree which computes monopole approximations of the
+// gravitational potential for a set of equal-mass particles.
+type octree struct {
+	nodes []octreeNode
+	x     [][3]float32
+	// idx is a permutation of the particles so that every node's particles
+	// are contiguous.
+	idx   []int32
+	stack []int32
+}
+
+type octreeNode struct {
+	center, com [3]float32
+	half, mass  float32
+	// start and end give the range of idx that's in this node. child is -1
+	// for leaves.
+	start, end int32
+	child      [8]int32
+}
+
+// build constructs the tree around the particles x, each with mass mp.
+func (t *octree) build(x [][3]float32, mp float32) {
+	t.x = x
+	t.nodes = t.nodes[:0]
+	t.idx = t.idx[:0]
+	for i := range x { t.idx = append(t.idx, int32(i)) }
+	if len(x) == 0 { return }
+
+	fb := PointBoundsNonPeriodic(x)
+	half := float32(0)
+	center := [3]float32{ }
+	for k := 0; k < 3; k++ {
+		center[k] = fb.Origin[k] + fb.Span[k]/2
+		if fb.Span[k]/2 > half { half = fb.Span[k]/2 }
+	}
+
+	t.buildNode(center, half*1.001 + octreeMinWidth, 0, int32(len(x)), mp)
+}
+
+// buildNode adds a node containing idx[start:end] to the tree and returns
+// its index.
+func (t *octree) buildNode(
+	center [3]float32, half float32, start, end int32, mp float32,
+) int32 {
+	ni := int32(len(t.nodes))
+	t.nodes = append(t.nodes, octreeNode{
+		center: center, half: half, start: start, end: end,
+		mass: mp*float32(end - start),
+	})
+	for c := range t.nodes[ni].child { t.nodes[ni].child[c] = -1 }
+
+	com := [3]float64{ }
+	for _, j := range t.idx[start: end] {
+		for k := 0; k < 3; k++ { com[k] += float64(t.x[j][k]) }
+	}
+	for k := 0; k < 3; k++ {
+		t.nodes[ni].com[k] = float32(com[k]/float64(end - start))
+	}
+
+	if end - start <= octreeLeafSize || half < octreeMinWidth { return ni }
+
+	// Sort particles into octants with a counting sort.
+	counts := [9]int32{ }
+	for _, j := range t.idx[start: end] {
+		counts[t.octant(center, j) + 1]++
+	}
+	for c := 1; c < len(counts); c++ { counts[c] += counts[c-1] }
+
+	sorted := make([]int32, end - start)
+	next := counts
+	for _, j := range t.idx[start: end] {
+		c := t.octant(center, j)
+		sorted[next[c]] = j
+		next[c]++
+	}
+	copy(t.idx[start: end], sorted)
+
+	for c := 0; c < 8; c++ {
+		if counts[c] == counts[c+1] { continue }
+		childCenter := center
+		for k := 0; k < 3; k++ {
+			if c & (1 << k) != 0 {
+				childCenter[k] += half/2
+			} else {
+				childCenter[k] -= half/2
+			}
+		}
+		child := t.buildNode(childCenter, half/2,
+			start + counts[c], start + counts[c+1], mp)
+		t.nodes[ni].child[c] = child
+	}
+
+	return ni
+}
+
+// octant returns which child of a node centered on center contains j.
+func (t *octree) octant(center [3]float32, j int32) int {
+	c := 0
+	for k := 0; k < 3; k++ {
+		if t.x[j][k] >= center[k] { c |= 1 << k }
+	}
+	return c
+}
+
+// potential returns the softened potential at particle i due to every other
+// particle in the tree, using G = 1 and opening angle theta.
+func (t *octree) potential(i int32, mp, eps, theta float32) float32 {
+	if len(t.nodes) == 0 { return 0 }
+
+	xi := t.x[i]
+	eps2, theta2 := eps*eps, theta*theta
+	phi := float64(0)
+
+	t.stack = append(t.stack[:0], 0)
+	for len(t.stack) > 0 {
+		node := &t.nodes[t.stack[len(t.stack) - 1]]
+		t.stack = t.stack[:len(t.stack) - 1]
+
+		if node.child == [8]int32{ -1, -1, -1, -1, -1, -1, -1, -1 } {
+			for _, j := range t.idx[node.start: node.end] {
+				if j == i { continue }
+				dr2 := dist2(xi, t.x[j])
+				phi -= float64(mp)/math.Sqrt(float64(dr2 + eps2))
+			}
+			continue
+		}
+
+		dr2 := dist2(xi, node.com)
+		width := 2*node.half
+		if width*width < theta2*dr2 && !node.contains(xi) {
+			phi -= float64(node.mass)/math.Sqrt(float64(dr2 + eps2))
+			continue
+		}
+
+		for _, c := range node.child {
+			if c != -1 { t.stack = append(t.stack, c) }
+		}
+	}
+
+	return float32(phi)
+}
+
+// contains returns true if x is inside the node.
+func (node *octreeNode) contains(x [3]float32) bool {
+	for k := 0; k < 3; k++ {
+		if x[k] < node.center[k] - node.half ||
+			x[k] > node.center[k] + node.half {
+			return false
+		}
+	}
+	return true
+}
+
+// dist2 returns the squared distance between two non-periodic points.
+func dist2(x1, x2 [3]float32) float32 {
+	dx, dy, dz := x1[0] - x2[0], x1[1] - x2[1], x1[2] - x2[2]
+	return dx*dx + dy*dy + dz*dz
+}
@@ -160,4 +160,30 @@ func  TestFindPairstwoCells(t *testing.T) {
 			)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestFindPairsCellWidth(t *testing.T) {
+	// With cells twice as wide along x, unit separations along x are two
+	// units apart.
+	x1 := [][3]float32{ {0, 0, 0}, {0.2, 0.5, 0} }
+	x2 := [][3]float32{ {0, 0, 0.9}, {0.4, 0, 0}, {0.7, 0, 0} }
+	p1, p2 := make([]Particle, len(x1)), make([]Particle, len(x2))
+	for j := range p1 { p1[j].X = x1[j] }
+	for j := range p2 { p2[j].X = x2[j] }
+
+	pair := &Pairer{ CellWidth: [3]float32{ 2, 1, 1 } }
+	for _, sortDim := range []int64{ -1, 0 } {
+		i1, i2 := pair.FindPairsTwoCells(p1, p2, 1, sortDim)
+		exp1, exp2 := []int64{ 0, 0, 1 }, []int64{ 0, 1, 1 }
+		if !edgesEqual(i1, i2, exp1, exp2) {
+			t.Errorf("sortDim = %d: expected edges %d %d, but got %d %d",
+				sortDim, exp1, exp2, i1, i2)
+		}
+
+		i1, i2 = pair.FindPairsOneCell(p2, 1, sortDim)
+		exp1, exp2 = []int64{ 1 }, []int64{ 2 }
+		if !edgesEqual(i1, i2, exp1, exp2) {
+			t.Errorf("sortDim = %d: expected edges %d %d, but got %d %d",
+				sortDim, exp1, exp2, i1, i2)
+		}
+	}
+}
@@ -10,21 +10,32 @@ import (
 type Pairer struct {
 	// If set to true, Pairer stops as soon as it has found a single pair.
 	StopEarly bool
+	// CellWidth is the length of one unit of the particles' coordinates
+	// along each axis, as for the code units made by BoxParticles. If set,
+	// separations are scaled by it, so r is in the box's units and cells
+	// don't need to be cubic. If left as zero, r is in the particles' units.
+	CellWidth [3]float32
 	// All of these are internal buffers that are meaningless to users.
 	i1, i2 []int64
 }
 
+// scale returns the scale factor applied to separations along each axis.
+func (pair *Pairer) scale() [3]float32 {
+	if pair.CellWidth == [3]float32{ } { return [3]float32{ 1, 1, 1 } }
+	return pair.CellWidth
+}
+
 func (pair *Pairer) FindPairsOneCell(
 	p []Particle, r float32, sortDim int64,
 ) (i1, i2 []int64) {
-	r2 := r*r
+	r2, s := r*r, pair.scale()
 	pair.i1, pair.i2 = pair.i1[:0], pair.i2[:0]
 	if sortDim == -1 {
 		for i := 0; i < len(p) - 1; i++ {
 			for j := i + 1; j < len(p); j++ {
-				dx := p[i].X[0] - p[j].X[0]
-				dy := p[i].X[1] - p[j].X[1]
-				dz := p[i].X[2] - p[j].X[2]
+				dx := (p[i].X[0] - p[j].X[0])*s[0]
+				dy := (p[i].X[1] - p[j].X[1])*s[1]
+				dz := (p[i].X[2] - p[j].X[2])*s[2]
 
 				dr2 := dx*dx + dy*dy + dz*dz
 
@@ -35,17 +46,18 @@ func (pair *Pairer) FindPairsOneCell(
 			}
 		}
 	} else {
+		rSort := r/s[sortDim]
 		high := 1
 		for i := 0; i < len(p)-1; i++ {
 			for ; high < len(p); high++ {
 				delta := p[high].X[sortDim] - p[i].X[sortDim]
-				if delta > r { break }
+				if delta > rSort { break }
 			}
 
 			for j := i+1; j < high; j++ {
-				dx := p[i].X[0] - p[j].X[0]
-				dy := p[i].X[1] - p[j].X[1]
-				dz := p[i].X[2] - p[j].X[2]
+				dx := (p[i].X[0] - p[j].X[0])*s[0]
+				dy := (p[i].X[1] - p[j].X[1])*s[1]
+				dz := (p[i].X[2] - p[j].X[2])*s[2]
 
 				dr2 := dx*dx + dy*dy + dz*dz
 
@@ -62,14 +74,14 @@ func (pair *Pairer) FindPairsOneCell(
 func (pair *Pairer) FindPairsTwoCells(
 	p1, p2 []Particle, r float32, sortDim int64,
 ) (i1, i2 []int64) {
-	r2 := r*r
+	r2, s := r*r, pair.scale()
 	pair.i1, pair.i2 = pair.i1[:0], pair.i2[:0]
 	if sortDim == -1 {
 		for i := range p1 {
 			for j := range p2 {
-				dx := p1[i].X[0] - p2[j].X[0]
-				dy := p1[i].X[1] - p2[j].X[1]
-				dz := p1[i].X[2] - p2[j].X[2]
+				dx := (p1[i].X[0] - p2[j].X[0])*s[0]
+				dy := (p1[i].X[1] - p2[j].X[1])*s[1]
+				dz := (p1[i].X[2] - p2[j].X[2])*s[2]
 
 				dr2 := dx*dx + dy*dy + dz*dz
 
@@ -80,21 +92,22 @@ func (pair *Pairer) FindPairsTwoCells(
 			}
 		}
 	} else {
+		rSort := r/s[sortDim]
 		low, high := 0, 0
 		for i := range p1 {
 			for ; low < len(p2); low++ {
 				delta := p1[i].X[sortDim] - p2[low].X[sortDim]
-				if delta <= r { break }
+				if delta <= rSort { break }
 			}
 			for ; high < len(p2); high++ {
 				delta := p2[high].X[sortDim] - p1[i].X[sortDim]
-				if delta > r { break }
+				if delta > rSort { break }
 			}
 
 			for j := low; j < high; j++ {
-				dx := p1[i].X[0] - p2[j].X[0]
-				dy := p1[i].X[1] - p2[j].X[1]
-				dz := p1[i].X[2] - p2[j].X[2]
+				dx := (p1[i].X[0] - p2[j].X[0])*s[0]
+				dy := (p1[i].X[1] - p2[j].X[1])*s[1]
+				dz := (p1[i].X[2] - p2[j].X[2])*s[2]
 
 				dr2 := dx*dx + dy*dy + dz*dz
 
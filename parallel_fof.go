@@ -0,0 +1,75 @@
+package symfof
+
+import (
+	"sync"
+)
+
+// ParallelFOF finds the same groups as CellFOF using the given number of
+// goroutines. The box is split into slabs of cells along the z-axis and each
+// slab is linked concurrently into a shared UnionFinder. Since no slab touches
+// the particles of another, this needs no locking. Pairs which cross slab
+// faces are collected by each worker and merged once all slabs are done.
+//
+// Group labels are roots of the UnionFinder and depend on the number of
+// workers, but the groups themselves do not.
+func ParallelFOF(
+	L float32, x, cen [][3]float32, r float32, nMin, workers int,
+) (groups, cenGroups []int32) {
+	cl := NewCellLinker(L, x, r)
+	uf := NewUnionFinder(int32(len(x)))
+	cl.ParallelLink(uf, workers)
+
+	groups = groupLabels(uf, nMin)
+
+	cenGroups = make([]int32, len(cen))
+	for i := range cenGroups {
+		if j := cl.Nearest(cen[i], r); j == -1 {
+			cenGroups[i] = -1
+		} else {
+			cenGroups[i] = groups[j]
+		}
+	}
+
+	return groups, cenGroups
+}
+
+// ParallelLink unions every pair of points closer than the linking length
+// using the given number of goroutines.
+func (cl *CellLinker) ParallelLink(uf *UnionFinder, workers int) {
+	if workers < 1 { workers = 1 }
+	if int64(workers) > cl.Cells[2] { workers = int(cl.Cells[2]) }
+
+	// Each worker only unions pairs inside its own slab and keeps the pairs
+	// that cross its faces for later.
+	faceI := make([][]int32, workers)
+	faceJ := make([][]int32, workers)
+	merged := make([]int32, workers)
+
+	wg := &sync.WaitGroup{ }
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			z0 := int64(w)*cl.Cells[2]/int64(workers)
+			z1 := int64(w + 1)*cl.Cells[2]/int64(workers)
+
+			cp := &cellPairer{ }
+			cl.linkCells(z0, z1, cp, func(i, j int32, local bool) {
+				if !local {
+					faceI[w] = append(faceI[w], i)
+					faceJ[w] = append(faceJ[w], j)
+				} else if uf.union(i, j) {
+					merged[w]++
+				}
+			})
+		}(w)
+	}
+	wg.Wait()
+
+	for w := 0; w < workers; w++ {
+		uf.NGroup -= merged[w]
+		for k := range faceI[w] {
+			uf.Union(faceI[w][k], faceJ[w][k])
+		}
+	}
+}
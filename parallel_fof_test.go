@@ -0,0 +1,53 @@
+package symfof
+
+import (
+	"testing"
+)
+
+func TestParallelFOF(t *testing.T) {
+	L, r := float32(40), float32(1.2)
+	x := randomPoints(5000, L, 10)
+	cen := x[:100]
+
+	groups, cenGroups := CellFOF(L, x, cen, r, 5)
+	for _, workers := range []int{ 1, 2, 3, 7, 64 } {
+		pGroups, pCenGroups := ParallelFOF(L, x, cen, r, 5, workers)
+		if !samePartition(groups, pGroups) {
+			t.Errorf("%d workers: ParallelFOF and CellFOF found different " +
+				"groups.", workers)
+		}
+		for i := range cenGroups {
+			if (cenGroups[i] == -1) != (pCenGroups[i] == -1) ||
+				(cenGroups[i] != -1 && pCenGroups[i] != pGroups[i]) {
+				t.Errorf("%d workers: center %d assigned to %d, expected a " +
+					"match to %d.", workers, i, pCenGroups[i], cenGroups[i])
+			}
+		}
+	}
+}
+
+func TestParallelLinkNGroup(t *testing.T) {
+	L, r := float32(40), float32(1.2)
+	x := randomPoints(3000, L, 11)
+
+	cl := NewCellLinker(L, x, r)
+	uf := NewUnionFinder(int32(len(x)))
+	cl.Link(uf)
+
+	for _, workers := range []int{ 1, 4 } {
+		puf := NewUnionFinder(int32(len(x)))
+		cl.ParallelLink(puf, workers)
+		if puf.NGroup != uf.NGroup {
+			t.Errorf("%d workers: NGroup = %d, expected %d.",
+				workers, puf.NGroup, uf.NGroup)
+		}
+	}
+}
+
+func TestParallelFOFEmpty(t *testing.T) {
+	groups, cenGroups := ParallelFOF(10, nil, [][3]float32{ {1, 2, 3} }, 1, 1, 4)
+	if len(groups) != 0 || !Int32Eq(cenGroups, []int32{ -1 }) {
+		t.Errorf("Expected no groups and cenGroups [-1], got %v and %v.",
+			groups, cenGroups)
+	}
+}
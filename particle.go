@@ -1,16 +1,29 @@
 package symfof
 
-// Particle represents a single particle. Most of the time particles will
+// Float is the set of types which can be used for positions and velocities.
+// float32 is used everywhere by default. float64 is for cases where float32
+// can't resolve small separations, such as zoom-ins in very large boxes.
+type Float interface {
+	~float32 | ~float64
+}
+
+// ParticleOf represents a single particle. Most of the time particles will
 // be in "code units" where positions are equal to the size of a single
 // internal FoF cell, and velocities are in units that lead to G being 1.
-type Particle struct {
+type ParticleOf[F Float] struct {
 	// ID is a 64-bit integer which uniquely identifies the particle.
 	ID uint64
 	// X and V are the position and velocity of the particle.
-	X, V [3]float32
+	X, V [3]F
 }
 
-func ParticleXCmp(p1, p2 Particle) int {
+// Particle is a particle with float32 positions and velocities.
+type Particle = ParticleOf[float32]
+
+// Particle64 is a particle with float64 positions and velocities.
+type Particle64 = ParticleOf[float64]
+
+func ParticleXCmp[F Float](p1, p2 ParticleOf[F]) int {
 	if p1.X[0] < p2.X[0] {
 		return -1
 	} else if p1.X[0] > p2.X[0] {
@@ -19,7 +32,7 @@ func ParticleXCmp(p1, p2 Particle) int {
 	return 0
 }
 
-func ParticleYCmp(p1, p2 Particle) int {
+func ParticleYCmp[F Float](p1, p2 ParticleOf[F]) int {
 	if p1.X[1] < p2.X[1] {
 		return -1
 	} else if p1.X[1] > p2.X[1] {
@@ -28,7 +41,7 @@ func ParticleYCmp(p1, p2 Particle) int {
 	return 0
 }
 
-func ParticleZCmp(p1, p2 Particle) int {
+func ParticleZCmp[F Float](p1, p2 ParticleOf[F]) int {
 	if p1.X[2] < p2.X[2] {
 		return -1
 	} else if p1.X[2] > p2.X[2] {
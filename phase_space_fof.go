@@ -0,0 +1,109 @@
+package symfof
+
+// PhaseSpaceFOF runs FOF on the particles p, which are in a periodic box of
+// width L, using the 6D distance
+//
+//     d^2 = |dx|^2/rx^2 + |dv|^2/rv^2
+//
+// Two particles are linked when d < 1, so rx and rv are the position and
+// velocity linking lengths. groups gives the root of each particle's group,
+// or -1 if that group has fewer than nMin members.
+func PhaseSpaceFOF(L float32, p []Particle, rx, rv float32, nMin int) []int32 {
+	invRv2 := make([]float32, len(p))
+	for i := range invRv2 { invRv2[i] = 1 / (rv*rv) }
+
+	cl := NewCellLinker(L, particlePositions(p), rx)
+	return phaseSpaceGroups(cl, L, p, rx, invRv2, nMin)
+}
+
+// AdaptivePhaseSpaceFOF is the same as PhaseSpaceFOF, except that the
+// velocity linking length is set separately within each 3D FOF group, like
+// ROCKSTAR does. The 3D groups are found with linking length rx, and the
+// velocity linking length of each is fv times its 3D velocity dispersion.
+func AdaptivePhaseSpaceFOF(
+	L float32, p []Particle, rx, fv float32, nMin int,
+) []int32 {
+	cl := NewCellLinker(L, particlePositions(p), rx)
+	uf := NewUnionFinder(int32(len(p)))
+	cl.Link(uf)
+	parents := groupLabels(uf, 1)
+
+	// Find the mean velocity of every parent group, then its velocity
+	// dispersion in a second pass, so the dispersion can't cancel out.
+	n := make([]float64, len(p))
+	v := make([][3]float64, len(p))
+	for i := range p {
+		j := parents[i]
+		n[j]++
+		for k := 0; k < 3; k++ { v[j][k] += float64(p[i].V[k]) }
+	}
+	for j := range v {
+		if n[j] == 0 { continue }
+		for k := 0; k < 3; k++ { v[j][k] /= n[j] }
+	}
+
+	sigma2 := make([]float64, len(p))
+	for i := range p {
+		j := parents[i]
+		for k := 0; k < 3; k++ {
+			dv := float64(p[i].V[k]) - v[j][k]
+			sigma2[j] += dv*dv
+		}
+	}
+
+	invRv2 := make([]float32, len(p))
+	for i := range p {
+		j := parents[i]
+		// If a group has no dispersion, all its members have the same
+		// velocity, so the velocity cut is turned off by leaving the inverse
+		// linking length at zero.
+		if sigma2[j] == 0 { continue }
+		invRv2[i] = float32(1/(float64(fv)*float64(fv)*sigma2[j]/n[j]))
+	}
+
+	return phaseSpaceGroups(cl, L, p, rx, invRv2, nMin)
+}
+
+// phaseSpaceGroups links every pair of particles found by cl whose 6D
+// distance is less than one. invRv2 gives the inverse squared velocity
+// linking length around each particle. Only the value of the first particle
+// in each pair is used, so invRv2 must be the same for every pair of
+// particles that cl could link, e.g. constant across each 3D FOF group.
+func phaseSpaceGroups(
+	cl *CellLinker, L float32, p []Particle, rx float32,
+	invRv2 []float32, nMin int,
+) []int32 {
+	uf := NewUnionFinder(int32(len(p)))
+	invRx2 := 1 / (rx*rx)
+
+	cl.LinkIf(uf, func(i, j int32) bool {
+		dx2, dv2 := float32(0), float32(0)
+		for k := 0; k < 3; k++ {
+			dx := SymBound(p[i].X[k] - p[j].X[k], L)
+			dv := p[i].V[k] - p[j].V[k]
+			dx2, dv2 = dx2 + dx*dx, dv2 + dv*dv
+		}
+		// Skipping equal velocities avoids 0*Inf for tiny dispersions.
+		d2 := dx2*invRx2
+		if dv2 > 0 { d2 += dv2*invRv2[i] }
+		return d2 < 1
+	})
+
+	return groupLabels(uf, nMin)
+}
+
+// LinkIf unions every pair of points closer than the linking length for
+// which accept returns true.
+func (cl *CellLinker) LinkIf(uf *UnionFinder, accept func(i, j int32) bool) {
+	cp := &cellPairer{ }
+	cl.linkCells(0, cl.Cells[2], cp, func(i, j int32, local bool) {
+		if accept(i, j) { uf.Union(i, j) }
+	})
+}
+
+// particlePositions returns the positions of an array of particles.
+func particlePositions(p []Particle) [][3]float32 {
+	x := make([][3]float32, len(p))
+	for i := range p { x[i] = p[i].X }
+	return x
+}
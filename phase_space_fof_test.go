@@ -0,0 +1,97 @@
+package symfof
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// overlappingClumps creates two clumps of particles which overlap in
+// position but have bulk velocities that differ by dv.
+func overlappingClumps(n int, dv float32) []Particle {
+	rng := rand.New(rand.NewSource(4))
+	p := make([]Particle, 2*n)
+	for i := range p {
+		p[i].ID = uint64(i)
+		for k := 0; k < 3; k++ {
+			p[i].X[k] = 20 + rng.Float32()
+			p[i].V[k] = rng.Float32()
+		}
+		if i >= n { p[i].V[0] += dv }
+	}
+	return p
+}
+
+func TestPhaseSpaceFOF(t *testing.T) {
+	L, n := float32(40), 200
+	p := overlappingClumps(n, 100)
+
+	x := particlePositions(p)
+	groups3D, _ := CellFOF(L, x, nil, 0.5, 1)
+	for i := range groups3D {
+		if groups3D[i] != groups3D[0] {
+			t.Fatalf("Expected a single 3D group, but particle %d is in " +
+				"group %d.", i, groups3D[i])
+		}
+	}
+
+	tests := []struct{
+		name string
+		groups []int32
+	} {
+		{ "PhaseSpaceFOF", PhaseSpaceFOF(L, p, 0.5, 10, 1) },
+		{ "AdaptivePhaseSpaceFOF", AdaptivePhaseSpaceFOF(L, p, 0.5, 0.5, 1) },
+	}
+
+	exp := make([]int32, 2*n)
+	for i := n; i < 2*n; i++ { exp[i] = 1 }
+	for i := range tests {
+		if !samePartition(tests[i].groups, exp) {
+			t.Errorf("%s did not split the clumps.", tests[i].name)
+		}
+	}
+
+	// With huge velocity linking lengths, the 3D groups come back.
+	groups := PhaseSpaceFOF(L, p, 0.5, 1e6, 1)
+	if !samePartition(groups, groups3D) {
+		t.Errorf("PhaseSpaceFOF with a large rv did not match CellFOF.")
+	}
+}
+
+func TestAdaptivePhaseSpaceFOFDispersion(t *testing.T) {
+	L, n := float32(40), 200
+
+	// Large bulk velocities with small dispersions cancel out if the
+	// dispersion is computed carelessly in float32.
+	p := overlappingClumps(n, 0)
+	for i := range p {
+		for k := 0; k < 3; k++ { p[i].V[k] = 1e4 + 1e-2*p[i].V[k] }
+		if i >= n { p[i].V[0] += 1 }
+	}
+
+	exp := make([]int32, 2*n)
+	for i := n; i < 2*n; i++ { exp[i] = 1 }
+	groups := AdaptivePhaseSpaceFOF(L, p, 0.5, 0.5, 1)
+	if !samePartition(groups, exp) {
+		t.Errorf("AdaptivePhaseSpaceFOF did not split clumps with a large " +
+			"bulk velocity.")
+	}
+
+	// A group with no dispersion is linked in 3D alone.
+	for i := range p { p[i].V = [3]float32{ 3, 2, 1 } }
+	groups3D, _ := CellFOF(L, particlePositions(p), nil, 0.5, 1)
+	groups = AdaptivePhaseSpaceFOF(L, p, 0.5, 0.5, 1)
+	if !samePartition(groups, groups3D) {
+		t.Errorf("AdaptivePhaseSpaceFOF with no dispersion did not match " +
+			"CellFOF.")
+	}
+}
+
+func TestPhaseSpaceFOFEmpty(t *testing.T) {
+	if groups := PhaseSpaceFOF(10, nil, 1, 1, 1); len(groups) != 0 {
+		t.Errorf("Expected no groups from PhaseSpaceFOF, got %v.", groups)
+	}
+	if groups := AdaptivePhaseSpaceFOF(10, nil, 1, 1, 1); len(groups) != 0 {
+		t.Errorf("Expected no groups from AdaptivePhaseSpaceFOF, got %v.",
+			groups)
+	}
+}
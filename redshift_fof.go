@@ -0,0 +1,121 @@
+package symfof
+
+import (
+	"fmt"
+	"math"
+)
+
+// RedshiftFOF finds galaxy groups in redshift space with the anisotropic
+// linking criteria of Huchra & Geller (1982) and Eke et al. (2004). The
+// separation between two galaxies is split into components perpendicular
+// and parallel to the line of sight from observer to the pair's midpoint.
+// The galaxies are linked if both components are within their linking
+// lengths, bPerp and bLOS.
+//
+// scale[i] multiplies the linking lengths of galaxy i and a pair uses the
+// mean of its two scales. This allows linking lengths to follow the local
+// number density or luminosity limit; see DensityScale. If scale is nil,
+// every galaxy has a scale of 1. There are no periodic boundaries. nGrid is
+// the number of grid cells along the widest axis of the survey. An error is
+// returned if any scale is negative or non-finite, or if the largest linking
+// length isn't finite.
+func RedshiftFOF(
+	x [][3]float32, observer [3]float32, bPerp, bLOS float32, scale []float32,
+	nGrid, nMin int,
+) ([]int32, error) {
+	uf := NewUnionFinder(int32(len(x)))
+	if len(x) == 0 { return groupLabels(uf, nMin), nil }
+
+	sMax := float32(1)
+	if scale != nil {
+		sMax = 0
+		for i, s := range scale {
+			if !(s >= 0) || math.IsInf(float64(s), 0) {
+				return nil, fmt.Errorf("RedshiftFOF was given a scale of " +
+					"%g for galaxy %d, but scales must be non-negative and " +
+					"finite.", s, i)
+			}
+			if s > sMax { sMax = s }
+		}
+	}
+	rMax := sMax*float32(math.Sqrt(float64(bPerp*bPerp + bLOS*bLOS)))
+	if math.IsInf(float64(rMax), 0) || math.IsNaN(float64(rMax)) {
+		return nil, fmt.Errorf("RedshiftFOF cannot search with a linking " +
+			"length of %g from bPerp = %g, bLOS = %g and a largest scale " +
+			"of %g.", rMax, bPerp, bLOS, sMax)
+	}
+
+	f := NewFinderBox(OpenBox(x), x, nGrid)
+	for i := int32(0); i < int32(len(x)); i++ {
+		for _, j := range f.Find(x[i], rMax) {
+			if j <= i { continue }
+
+			s := float32(1)
+			if scale != nil { s = (scale[i] + scale[j])/2 }
+			perp, los := losSeparation(x[i], x[j], observer)
+			if perp <= s*bPerp && los <= s*bLOS { uf.Union(i, j) }
+		}
+	}
+
+	return groupLabels(uf, nMin), nil
+}
+
+// losSeparation returns the absolute components of the separation between
+// x1 and x2 which are perpendicular and parallel to the line of sight from
+// observer to their midpoint.
+func losSeparation(x1, x2, observer [3]float32) (perp, los float32) {
+	mid, dx := [3]float32{ }, [3]float32{ }
+	mid2 := float32(0)
+	for k := 0; k < 3; k++ {
+		mid[k] = (x1[k] + x2[k])/2 - observer[k]
+		dx[k] = x1[k] - x2[k]
+		mid2 += mid[k]*mid[k]
+	}
+
+	dr2, dot := float32(0), float32(0)
+	for k := 0; k < 3; k++ {
+		dr2 += dx[k]*dx[k]
+		dot += dx[k]*mid[k]
+	}
+
+	// A pair centered on the observer has no line of sight, so all of its
+	// separation is treated as line-of-sight.
+	if mid2 == 0 { return 0, float32(math.Sqrt(float64(dr2))) }
+
+	los2 := dot*dot/mid2
+	perp2 := dr2 - los2
+	if perp2 < 0 { perp2 = 0 }
+	return float32(math.Sqrt(float64(perp2))), float32(math.Sqrt(float64(los2)))
+}
+
+// DensityScale returns linking length scales for RedshiftFOF which keep the
+// linking lengths at a fixed fraction of the mean inter-galaxy separation.
+// nbar[i] is the expected number density of galaxies at the distance of
+// galaxy i in a flux-limited survey and nbar0 is the density where the scale
+// is 1. An error is returned if nbar0 or any element of nbar isn't positive
+// and finite, since the scale would be zero, infinite or NaN.
+func DensityScale(nbar []float32, nbar0 float32) ([]float32, error) {
+	if !positiveFinite(nbar0) {
+		return nil, fmt.Errorf("DensityScale was given nbar0 = %g, but it " +
+			"must be positive and finite.", nbar0)
+	}
+
+	scale := make([]float32, len(nbar))
+	for i := range scale {
+		if !positiveFinite(nbar[i]) {
+			return nil, fmt.Errorf("DensityScale was given nbar[%d] = %g, " +
+				"but densities must be positive and finite.", i, nbar[i])
+		}
+		scale[i] = float32(math.Cbrt(float64(nbar0/nbar[i])))
+		if !positiveFinite(scale[i]) {
+			return nil, fmt.Errorf("DensityScale found a scale of %g for " +
+				"nbar[%d] = %g and nbar0 = %g.", scale[i], i, nbar[i], nbar0)
+		}
+	}
+	return scale, nil
+}
+
+// positiveFinite returns true if x is positive and not infinite.
+func positiveFinite(x float32) bool {
+	return x > 0 && !math.IsInf(float64(x), +1)
+}
@@ -0,0 +1,136 @@
+package symfof
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLOSSeparation(t *testing.T) {
+	tests := []struct{
+		x1, x2, observer [3]float32
+		perp, los float32
+	} {
+		{ [3]float32{100, 1, 0}, [3]float32{100, -1, 0}, [3]float32{}, 2, 0 },
+		{ [3]float32{100, 0, 0}, [3]float32{103, 0, 0}, [3]float32{}, 0, 3 },
+		{ [3]float32{0, 0, 3}, [3]float32{4, 0, 3}, [3]float32{2, 0, 0}, 4, 0 },
+		{ [3]float32{10, 10, 0}, [3]float32{13, 14, 0}, [3]float32{11.5, 0, 0},
+			3, 4 },
+	}
+
+	for i := range tests {
+		perp, los := losSeparation(tests[i].x1, tests[i].x2, tests[i].observer)
+		if !almostEq(perp, tests[i].perp) || !almostEq(los, tests[i].los) {
+			t.Errorf("%d) expected separations %g and %g, got %g and %g.", i,
+				tests[i].perp, tests[i].los, perp, los)
+		}
+	}
+}
+
+func TestRedshiftFOF(t *testing.T) {
+	observer := [3]float32{ -50, 0, 0 }
+	x := [][3]float32{
+		{50, 0, 0}, // Finger of god along the line of sight
+		{53, 0, 0},
+		{56, 0.5, 0},
+
+		{50, 20, 0}, // The same separations across the line of sight
+		{50, 23, 0},
+		{50, 26, 0.5},
+	}
+
+	groups, err := RedshiftFOF(x, observer, 1, 4, nil, 10, 2)
+	if err != nil { t.Fatalf("RedshiftFOF failed: %s", err.Error()) }
+	if groups[0] == -1 || groups[0] != groups[1] || groups[0] != groups[2] {
+		t.Errorf("Expected galaxies along the line of sight to be linked, " +
+			"got groups %d.", groups[:3])
+	}
+	for i := 3; i < 6; i++ {
+		if groups[i] != -1 {
+			t.Errorf("Expected galaxy %d to be unlinked, got group %d.",
+				i, groups[i])
+		}
+	}
+
+	// Doubling the scale of the second set of galaxies links them.
+	scale := []float32{ 1, 1, 1, 2, 2, 2 }
+	groups, err = RedshiftFOF(x, observer, 2, 4, scale, 10, 2)
+	if err != nil { t.Fatalf("RedshiftFOF failed: %s", err.Error()) }
+	if groups[3] == -1 || groups[3] != groups[4] || groups[3] != groups[5] {
+		t.Errorf("Expected scaled galaxies to be linked, got groups %d.",
+			groups[3:])
+	}
+}
+
+func TestRedshiftFOFBruteForce(t *testing.T) {
+	x := randomPoints(1500, 40, 23)
+	observer := [3]float32{ 20, -30, 10 }
+	scale := make([]float32, len(x))
+	for i := range scale { scale[i] = 0.5 + x[i][1]/40 }
+	bPerp, bLOS := float32(0.8), float32(3)
+
+	uf := NewUnionFinder(int32(len(x)))
+	for i := range x {
+		for j := i + 1; j < len(x); j++ {
+			s := (scale[i] + scale[j])/2
+			perp, los := losSeparation(x[i], x[j], observer)
+			if perp <= s*bPerp && los <= s*bLOS {
+				uf.Union(int32(i), int32(j))
+			}
+		}
+	}
+
+	groups, err := RedshiftFOF(x, observer, bPerp, bLOS, scale, 20, 3)
+	if err != nil { t.Fatalf("RedshiftFOF failed: %s", err.Error()) }
+	if !samePartition(groups, groupLabels(uf, 3)) {
+		t.Errorf("RedshiftFOF groups do not match brute force linking.")
+	}
+}
+
+func TestDensityScale(t *testing.T) {
+	scale, err := DensityScale([]float32{ 1, 8, 1.0/8 }, 1)
+	if err != nil { t.Fatalf("DensityScale failed: %s", err.Error()) }
+	exp := []float32{ 1, 0.5, 2 }
+	for i := range scale {
+		if !almostEq(scale[i], exp[i]) {
+			t.Errorf("Expected scale %g, got %g.", exp, scale)
+			break
+		}
+	}
+
+	// Densities which would give infinite or zero scales are errors, as is
+	// a ratio which overflows.
+	inf := float32(math.Inf(+1))
+	tests := []struct{
+		nbar []float32
+		nbar0 float32
+	} {
+		{ []float32{ 1, 0, 1 }, 1 }, { []float32{ 1, -1 }, 1 },
+		{ []float32{ 1, inf }, 1 }, { []float32{ 1 }, 0 },
+		{ []float32{ 1 }, inf }, { []float32{ 1e-30 }, 1e30 },
+	}
+	for i := range tests {
+		if _, err := DensityScale(tests[i].nbar, tests[i].nbar0); err == nil {
+			t.Errorf("%d) Expected an error from DensityScale(%g, %g).",
+				i, tests[i].nbar, tests[i].nbar0)
+		}
+	}
+}
+
+func TestRedshiftFOFBadScales(t *testing.T) {
+	x := randomPoints(20, 40, 24)
+	inf, nan := float32(math.Inf(+1)), float32(math.NaN())
+	for _, bad := range []float32{ inf, nan, -1 } {
+		scale := make([]float32, len(x))
+		for i := range scale { scale[i] = 1 }
+		scale[3] = bad
+		_, err := RedshiftFOF(x, [3]float32{ }, 1, 4, scale, 10, 2)
+		if err == nil {
+			t.Errorf("Expected an error from RedshiftFOF with a scale of %g.",
+				bad)
+		}
+	}
+
+	if _, err := RedshiftFOF(x, [3]float32{ }, inf, 4, nil, 10, 2); err == nil {
+		t.Errorf("Expected an error from RedshiftFOF with bPerp = %g.", inf)
+	}
+}
@@ -0,0 +1,59 @@
+package symfof
+
+import (
+	"slices"
+)
+
+// RelabelGroups replaces the labels returned by FOF with contiguous IDs
+// 0 to N-1, ordered by decreasing group size. Ties are broken by the
+// smallest member Particle.ID, where ids[i] is the ID of particle i. If ids is
+// nil, ties are broken by the smallest member index instead. cenGroups is
+// relabeled in the same way. Both arrays are modified in place, and -1 is
+// left unchanged.
+//
+// The returned array maps new IDs back to the original labels.
+func RelabelGroups(groups, cenGroups []int32, ids []uint64) (roots []int32) {
+	roots = groupOrder(groups, ids)
+
+	index := make(map[int32]int32, len(roots))
+	for i, g := range roots { index[g] = int32(i) }
+
+	for i, g := range groups {
+		if g != -1 { groups[i] = index[g] }
+	}
+	for i, g := range cenGroups {
+		if g != -1 { cenGroups[i] = index[g] }
+	}
+
+	return roots
+}
+
+// groupOrder returns the labels of every group, sorted by decreasing size
+// with ties broken by the smallest member ID. If ids is nil, particle indices
+// are used as IDs.
+func groupOrder(groups []int32, ids []uint64) []int32 {
+	size, minID := map[int32]int32{ }, map[int32]uint64{ }
+	for i, g := range groups {
+		if g == -1 { continue }
+		id := uint64(i)
+		if ids != nil { id = ids[i] }
+
+		if prev, ok := minID[g]; !ok || id < prev { minID[g] = id }
+		size[g]++
+	}
+
+	roots := make([]int32, 0, len(size))
+	for g := range size { roots = append(roots, g) }
+	slices.SortFunc(roots, func(g1, g2 int32) int {
+		if size[g1] != size[g2] {
+			return int(size[g2] - size[g1])
+		} else if minID[g1] < minID[g2] {
+			return -1
+		} else if minID[g1] > minID[g2] {
+			return +1
+		}
+		return 0
+	})
+
+	return roots
+}
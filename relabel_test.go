@@ -0,0 +1,66 @@
+package symfof
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRelabelGroups(t *testing.T) {
+	groups := []int32{ 7, -1, 3, 3, 9, 7, 9, 3, 5 }
+	cenGroups := []int32{ 9, -1, 3, 5 }
+	ids := []uint64{ 40, 0, 10, 11, 30, 41, 31, 12, 50 }
+
+	roots := RelabelGroups(groups, cenGroups, ids)
+
+	// Group 3 is the largest. Groups 7 and 9 tie, but 9 has the smaller ID.
+	expRoots := []int32{ 3, 9, 7, 5 }
+	expGroups := []int32{ 2, -1, 0, 0, 1, 2, 1, 0, 3 }
+	expCen := []int32{ 1, -1, 0, 3 }
+	if !Int32Eq(roots, expRoots) {
+		t.Errorf("Expected roots %d, got %d.", expRoots, roots)
+	}
+	if !Int32Eq(groups, expGroups) {
+		t.Errorf("Expected groups %d, got %d.", expGroups, groups)
+	}
+	if !Int32Eq(cenGroups, expCen) {
+		t.Errorf("Expected cenGroups %d, got %d.", expCen, cenGroups)
+	}
+
+	// Without IDs, ties go to the smallest index: 7 appears first.
+	groups = []int32{ 7, -1, 3, 3, 9, 7, 9, 3, 5 }
+	roots = RelabelGroups(groups, nil, nil)
+	if exp := []int32{ 3, 7, 9, 5 }; !Int32Eq(roots, exp) {
+		t.Errorf("Expected roots %d, got %d.", exp, roots)
+	}
+}
+
+func TestRelabelGroupsReproducible(t *testing.T) {
+	L, r := float32(40), float32(1.2)
+	x := randomPoints(3000, L, 15)
+	ids := make([]uint64, len(x))
+	for i := range ids { ids[i] = uint64(i) }
+
+	groups, cenGroups := FOF(L, x, x[:50], r, 20, 3)
+	RelabelGroups(groups, cenGroups, ids)
+
+	// Shuffling the particles changes the roots, but not the new IDs.
+	perm := rand.New(rand.NewSource(16)).Perm(len(x))
+	px, pids := make([][3]float32, len(x)), make([]uint64, len(x))
+	for i, j := range perm { px[i], pids[i] = x[j], ids[j] }
+
+	pGroups, pCenGroups := FOF(L, px, x[:50], r, 20, 3)
+	RelabelGroups(pGroups, pCenGroups, pids)
+
+	for i, j := range perm {
+		if pGroups[i] != groups[j] {
+			t.Errorf("Particle %d has ID %d after shuffling, expected %d.",
+				j, pGroups[i], groups[j])
+		}
+	}
+	for i := range cenGroups {
+		if cenGroups[i] != -1 && pCenGroups[i] != cenGroups[i] {
+			t.Errorf("Center %d has ID %d after shuffling, expected %d.",
+				i, pCenGroups[i], cenGroups[i])
+		}
+	}
+}
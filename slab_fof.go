@@ -0,0 +1,222 @@
+package symfof
+
+import (
+	"fmt"
+)
+
+// SlabSource supplies the particles in a snapshot one slab at a time, so
+// that the full snapshot never needs to be in memory. The box is split into
+// Slabs() slabs of equal width along the x-axis.
+type SlabSource interface {
+	// Slabs returns the number of slabs.
+	Slabs() int
+	// ReadSlab returns the positions and global indices of every particle
+	// in slab i, followed by every particle in other slabs which is within
+	// ghost of slab i's faces, accounting for periodic boundaries. nOwned
+	// is the number of particles in slab i. x and idx may be used as
+	// buffers, so callers should use the returned arrays instead.
+	ReadSlab(i int, ghost float32, x [][3]float32, idx []int64) (
+		xOut [][3]float32, idxOut []int64, nOwned int, err error,
+	)
+}
+
+// LabelSink receives the final group labels of each slab's particles.
+type LabelSink interface {
+	// WriteLabels writes the labels of the particles with the given global
+	// indices. Labels are -1 for particles that aren't in a group.
+	WriteLabels(idx, labels []int64) error
+}
+
+// SlabFOF runs FOF on a snapshot which is too big to fit in memory. Each slab
+// is linked separately along with a ghost layer one linking length thick.
+// Groups which touch slab faces are stitched together with a union-find over
+// only the particles near those faces.
+//
+// Slabs are read twice: once to stitch the groups together and once to
+// write out the final labels. A group's label is the smallest global index
+// of its members. L is the width of the periodic box and nGrid and nMin are
+// the same as in FOF. An error is returned if src has no slabs, or if there
+// are several slabs and a slab plus its ghost layers is too close to L wide.
+func SlabFOF(
+	L float32, src SlabSource, out LabelSink, r float32, nGrid, nMin int,
+) error {
+	n := src.Slabs()
+	if n < 1 {
+		return fmt.Errorf("SlabFOF needs at least one slab, but the " +
+			"SlabSource has %d.", n)
+	}
+	w := L/float32(n)
+	if n > 1 && w + 3*r >= L {
+		return fmt.Errorf("SlabFOF cannot use a linking length of %g with " +
+			"%d slabs in a box with width %g.", r, n, L)
+	}
+
+	sl := &slabLinker{ L: L, src: src, r: r, w: w, nGrid: nGrid }
+	b := &slabBoundary{ index: map[int64]int32{ } }
+
+	for i := 0; i < n; i++ {
+		if err := sl.link(i); err != nil { return err }
+		b.addSlab(sl)
+	}
+	b.stitch()
+
+	labels := []int64{ }
+	for i := 0; i < n; i++ {
+		if err := sl.link(i); err != nil { return err }
+
+		labels = labels[:0]
+		for j := 0; j < sl.nOwned; j++ {
+			root := sl.uf.Find(int32(j))
+			label, size := sl.label[root], int64(sl.owned[root])
+			if node, ok := b.index[label]; ok {
+				node = b.uf.Find(node)
+				label, size = b.label[node], b.size[node]
+			}
+
+			if size < int64(nMin) { label = -1 }
+			labels = append(labels, label)
+		}
+
+		if err := out.WriteLabels(sl.idx[:sl.nOwned], labels); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// slabLinker links the particles in a single slab.
+type slabLinker struct {
+	L, r, w float32
+	nGrid   int
+	src     SlabSource
+
+	x      [][3]float32
+	idx    []int64
+	nOwned int
+	// xs are the positions after shifting the slab to start at zero.
+	xs [][3]float32
+	uf *UnionFinder
+	// label is the smallest global index of the owned members of each
+	// local root and owned is the number of owned members.
+	label []int64
+	owned []int32
+}
+
+// link reads and links slab i.
+func (sl *slabLinker) link(i int) error {
+	var err error
+	sl.x, sl.idx, sl.nOwned, err = sl.src.ReadSlab(i, sl.r, sl.x, sl.idx)
+	if err != nil { return err }
+
+	// Shift the slab so that it and its ghosts are contiguous and start at
+	// zero.
+	sl.xs = append(sl.xs[:0], sl.x...)
+	x := sl.xs
+	origin := float32(i)*sl.w - sl.r
+	for j := range x {
+		x[j][0] = Bound(x[j][0] - origin, sl.L)
+	}
+
+	sl.uf = NewUnionFinder(int32(len(x)))
+	if len(x) > 0 {
+		linkFinder(NewFinder(sl.L, x, sl.nGrid), x, sl.r, sl.uf)
+	}
+
+	sl.label, sl.owned = make([]int64, len(x)), make([]int32, len(x))
+	for j := range sl.label { sl.label[j] = -1 }
+	for j := 0; j < sl.nOwned; j++ {
+		root := sl.uf.Find(int32(j))
+		if sl.label[root] == -1 || sl.idx[j] < sl.label[root] {
+			sl.label[root] = sl.idx[j]
+		}
+		sl.owned[root]++
+	}
+
+	return nil
+}
+
+// onBoundary returns true if local particle j is a ghost or is close enough
+// to a face to be a ghost in another slab.
+func (sl *slabLinker) onBoundary(j int) bool {
+	if j >= sl.nOwned { return true }
+	// The slab covers [r, w + r) in shifted coordinates. A small tolerance
+	// keeps rounding from hiding particles right at the edge of the ghost
+	// layer.
+	tol := sl.r*1e-3
+	return sl.xs[j][0] <= 2*sl.r + tol || sl.xs[j][0] >= sl.w - tol
+}
+
+// slabBoundary is a union-find over the global indices of particles that are
+// near slab faces.
+type slabBoundary struct {
+	index        map[int64]int32
+	keys         []int64
+	count        []int64
+	edgeI, edgeJ []int32
+
+	uf *UnionFinder
+	// label and size are the final label and size of each root.
+	label, size []int64
+}
+
+// node returns the node of a global index, creating it if needed.
+func (b *slabBoundary) node(key int64) int32 {
+	if node, ok := b.index[key]; ok { return node }
+	node := int32(len(b.keys))
+	b.index[key] = node
+	b.keys = append(b.keys, key)
+	b.count = append(b.count, 0)
+	return node
+}
+
+// addSlab records every local group in a linked slab which touches a face.
+func (b *slabBoundary) addSlab(sl *slabLinker) {
+	b.addLinked(sl.uf, sl.label, sl.owned, sl.idx, sl.onBoundary)
+}
+
+// addLinked records every local group in uf which contains a particle on the
+// boundary. label and owned are the label and owned member count of each
+// local root, idx are the global indices of the local particles, and
+// onBoundary reports whether a local particle is on the boundary.
+func (b *slabBoundary) addLinked(
+	uf *UnionFinder, label []int64, owned []int32, idx []int64,
+	onBoundary func(j int) bool,
+) {
+	counted := map[int32]bool{ }
+	for j := range idx {
+		if !onBoundary(j) { continue }
+		root := uf.Find(int32(j))
+		if label[root] == -1 { continue }
+
+		node := b.node(label[root])
+		if !counted[root] {
+			b.count[node] += int64(owned[root])
+			counted[root] = true
+		}
+		b.edgeI = append(b.edgeI, node)
+		b.edgeJ = append(b.edgeJ, b.node(idx[j]))
+	}
+}
+
+// stitch unions every recorded edge and finds the label and size of each
+// stitched group.
+func (b *slabBoundary) stitch() {
+	b.uf = NewUnionFinder(int32(len(b.keys)))
+	for k := range b.edgeI { b.uf.Union(b.edgeI[k], b.edgeJ[k]) }
+	b.resolve()
+}
+
+// resolve finds the label and size of each group in b.uf.
+func (b *slabBoundary) resolve() {
+	n := int32(len(b.keys))
+	b.label, b.size = make([]int64, n), make([]int64, n)
+	for i := range b.label { b.label[i] = -1 }
+	for i := int32(0); i < n; i++ {
+		root := b.uf.Find(i)
+		b.size[root] += b.count[i]
+		if b.label[root] == -1 || b.keys[i] < b.label[root] {
+			b.label[root] = b.keys[i]
+		}
+	}
+}
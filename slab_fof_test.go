@@ -0,0 +1,115 @@
+package symfof
+
+import (
+	"testing"
+)
+
+// memorySlabSource is a SlabSource for particles that are already in memory.
+type memorySlabSource struct {
+	L float32
+	x [][3]float32
+	n int
+}
+
+func (src *memorySlabSource) Slabs() int { return src.n }
+
+func (src *memorySlabSource) ReadSlab(
+	i int, ghost float32, x [][3]float32, idx []int64,
+) ([][3]float32, []int64, int, error) {
+	w := src.L/float32(src.n)
+	lo, hi := float32(i)*w, float32(i + 1)*w
+	slab := func(x float32) int {
+		j := int(x/w)
+		if j >= src.n { j = src.n - 1 }
+		return j
+	}
+
+	x, idx = x[:0], idx[:0]
+	for j := range src.x {
+		if slab(src.x[j][0]) == i {
+			x, idx = append(x, src.x[j]), append(idx, int64(j))
+		}
+	}
+	nOwned := len(x)
+
+	if src.n == 1 { return x, idx, nOwned, nil }
+	for j := range src.x {
+		xj := src.x[j][0]
+		if slab(xj) == i { continue }
+		if Bound(lo - xj, src.L) <= ghost || Bound(xj - hi, src.L) <= ghost {
+			x, idx = append(x, src.x[j]), append(idx, int64(j))
+		}
+	}
+
+	return x, idx, nOwned, nil
+}
+
+// memoryLabelSink is a LabelSink which stores labels in an array.
+type memoryLabelSink struct {
+	labels []int64
+}
+
+func (sink *memoryLabelSink) WriteLabels(idx, labels []int64) error {
+	for i := range idx { sink.labels[idx[i]] = labels[i] }
+	return nil
+}
+
+func TestSlabFOF(t *testing.T) {
+	// The larger linking length percolates, so groups cross every slab.
+	for _, r := range []float32{ 1.2, 1.6 } {
+		testSlabFOF(t, 40, r)
+	}
+}
+
+func testSlabFOF(t *testing.T, L, r float32) {
+	x := randomPoints(5000, L, 13)
+	groups, _ := CellFOF(L, x, nil, r, 3)
+
+	minIdx := map[int32]int64{ }
+	for i := len(groups) - 1; i >= 0; i-- {
+		if groups[i] != -1 { minIdx[groups[i]] = int64(i) }
+	}
+
+	for _, n := range []int{ 1, 2, 3, 5 } {
+		src := &memorySlabSource{ L, x, n }
+		sink := &memoryLabelSink{ make([]int64, len(x)) }
+		if err := SlabFOF(L, src, sink, r, 20, 3); err != nil {
+			t.Fatalf("r = %g, %d slabs: SlabFOF failed: %s", r, n, err.Error())
+		}
+
+		slabGroups := make([]int32, len(x))
+		for i := range slabGroups {
+			slabGroups[i] = int32(sink.labels[i])
+			if groups[i] != -1 && sink.labels[i] != minIdx[groups[i]] {
+				t.Errorf("r = %g, %d slabs: particle %d has label %d, " +
+					"expected %d.", r, n, i, sink.labels[i], minIdx[groups[i]])
+				break
+			}
+		}
+		if !samePartition(groups, slabGroups) {
+			t.Errorf("r = %g, %d slabs: SlabFOF and CellFOF found " +
+				"different groups.", r, n)
+		}
+	}
+}
+
+func TestSlabFOFErrors(t *testing.T) {
+	L := float32(40)
+	x := randomPoints(100, L, 14)
+	tests := []struct{
+		n int
+		r float32
+	} {
+		// No slabs, then slabs which overlap their own ghosts.
+		{ 0, 1 }, { 4, 12 }, { 2, 7 },
+	}
+
+	for i := range tests {
+		src := &memorySlabSource{ L, x, tests[i].n }
+		sink := &memoryLabelSink{ make([]int64, len(x)) }
+		if err := SlabFOF(L, src, sink, tests[i].r, 20, 3); err == nil {
+			t.Errorf("%d) Expected an error from SlabFOF with %d slabs and " +
+				"r = %g.", i, tests[i].n, tests[i].r)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package symfof
+
+import (
+	"fmt"
+	"math"
+)
+
+// SOOverlap decides which particles count towards a halo's spherical
+// overdensity mass.
+type SOOverlap int
+
+const (
+	// Inclusive counts every particle inside the sphere.
+	Inclusive SOOverlap = iota
+	// Exclusive counts members of the halo's FOF group and particles which
+	// aren't in any group, but not members of other groups.
+	Exclusive
+	// Strict only counts members of the halo's FOF group.
+	Strict
+)
+
+// SOMasses holds the spherical overdensity masses of a single halo and the
+// radii that enclose them.
+type SOMasses struct {
+	M200c, R200c   float32
+	M200m, R200m   float32
+	MVir, RVir     float32
+	MDelta, RDelta float32
+}
+
+// SOFinder measures spherical overdensity masses by growing spheres around
+// halo centers. Spheres are searched with a Finder over every particle, not
+// just FOF members, and the Overlap field decides which ones count.
+//
+// All densities are in the same code units as the particle mass and
+// positions. Overdensities whose target density is zero are skipped and
+// reported with zero mass and radius.
+type SOFinder struct {
+	// L is the width of the periodic box and Mp is the mass of a single
+	// particle.
+	L, Mp float32
+	// RhoCrit and RhoMean are the critical and mean matter densities.
+	RhoCrit, RhoMean float32
+	// DeltaVir is the virial overdensity relative to RhoCrit. See
+	// BryanNormanDeltaVir.
+	DeltaVir float32
+	// Delta is a user-defined overdensity, which is relative to RhoMean if
+	// DeltaMean is true and RhoCrit otherwise.
+	Delta     float32
+	DeltaMean bool
+	// Overlap controls which particles are counted.
+	Overlap SOOverlap
+
+	finder  *Finder
+	groups  []int32
+	distBuf []float32
+}
+
+// NewSOFinder creates an SOFinder for particles at x, which are in a
+// periodic box of width L, each with mass mp. rhoCrit and rhoMean are the
+// critical and mean matter densities, and DeltaVir is set from their ratio
+// with BryanNormanDeltaVir. groups gives the FOF group of each particle and
+// is only needed for the Exclusive and Strict overlap modes. nGrid is the
+// number of grid cells used by the internal Finder.
+func NewSOFinder(
+	L, mp, rhoCrit, rhoMean float32, x [][3]float32, groups []int32,
+	nGrid int,
+) *SOFinder {
+	if rhoCrit <= 0 || rhoMean <= 0 {
+		panic(fmt.Sprintf("SOFinder needs positive densities, but got " +
+			"rhoCrit = %g and rhoMean = %g.", rhoCrit, rhoMean))
+	}
+
+	omegaM := float64(rhoMean)/float64(rhoCrit)
+	return &SOFinder{
+		L: L, Mp: mp, RhoCrit: rhoCrit, RhoMean: rhoMean,
+		DeltaVir: float32(BryanNormanDeltaVir(omegaM)),
+		Delta: 200, Overlap: Inclusive,
+		finder: NewFinder(L, x, nGrid), groups: groups,
+	}
+}
+
+// BryanNormanDeltaVir returns the Bryan & Norman (1998) virial overdensity
+// relative to the critical density, where omegaM is the matter density
+// parameter at the redshift of interest.
+func BryanNormanDeltaVir(omegaM float64) float64 {
+	x := omegaM - 1
+	return 18*math.Pi*math.Pi + 82*x - 39*x*x
+}
+
+// Masses measures the SO masses around the center cen of a halo in the FOF
+// group with label group. r0 is the starting search radius, which is
+// doubled until every overdensity boundary has been found or the sphere
+// reaches half the box width.
+func (s *SOFinder) Masses(cen [3]float32, group int32, r0 float32) SOMasses {
+	rhos := [4]float32{
+		200*s.RhoCrit, 200*s.RhoMean, s.DeltaVir*s.RhoCrit, s.Delta*s.RhoCrit,
+	}
+	if s.DeltaMean { rhos[3] = s.Delta*s.RhoMean }
+
+	rMax := s.L/2*0.999
+	if r0 <= 0 { r0 = s.L/1000 }
+	if r0 > rMax { r0 = rMax }
+
+	var n [4]int
+	for r := r0; ; r *= 2 {
+		if r > rMax { r = rMax }
+		s.sortedDistances(cen, group, r)
+
+		done := true
+		for i := range rhos {
+			if rhos[i] <= 0 { continue }
+			var found bool
+			n[i], found = soCount(s.distBuf, s.Mp, rhos[i], r)
+			done = done && found
+		}
+		if done || r == rMax { break }
+	}
+
+	var m, rad [4]float32
+	for i := range rhos {
+		if rhos[i] <= 0 { continue }
+		m[i] = float32(n[i])*s.Mp
+		vol := float64(m[i])/float64(rhos[i])
+		rad[i] = float32(math.Cbrt(3*vol/(4*math.Pi)))
+	}
+
+	return SOMasses{
+		M200c: m[0], R200c: rad[0], M200m: m[1], R200m: rad[1],
+		MVir: m[2], RVir: rad[2], MDelta: m[3], RDelta: rad[3],
+	}
+}
+
+// sortedDistances fills s.distBuf with the sorted distances to every
+// counted particle within r of cen.
+func (s *SOFinder) sortedDistances(cen [3]float32, group int32, r float32) {
+	s.distBuf = s.distBuf[:0]
+	idx, dist := s.finder.FindDist(cen, r, true)
+	for i, j := range idx {
+		switch s.Overlap {
+		case Exclusive:
+			if s.groups[j] != group && s.groups[j] != -1 { continue }
+		case Strict:
+			if s.groups[j] != group { continue }
+		}
+		s.distBuf = append(s.distBuf, dist[i])
+	}
+}
+
+// soCount returns the number of particles inside the overdensity boundary
+// with density rho given the sorted distances to every particle within r.
+// found is false if the boundary could be outside r.
+func soCount(dist []float32, mp, rho, r float32) (n int, found bool) {
+	for i := range dist {
+		vol := 4*math.Pi/3*math.Pow(float64(dist[i]), 3)
+		if float64(mp)*float64(i + 1) < float64(rho)*vol {
+			return i, true
+		}
+	}
+
+	vol := 4*math.Pi/3*math.Pow(float64(r), 3)
+	return len(dist), float64(mp)*float64(len(dist)) < float64(rho)*vol
+}
+
+// SOMasses measures the SO masses of every halo in the catalog around its
+// center of mass.
+func (c *Catalog) SOMasses(s *SOFinder) []SOMasses {
+	out := make([]SOMasses, len(c.Halos))
+	for i, h := range c.Halos {
+		out[i] = s.Masses(h.X, h.Root, h.RMax)
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+package symfof
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// uniformSphere generates n points uniformly distributed in a sphere.
+func uniformSphere(
+	n int, cen [3]float32, rMin, rMax float32, rng *rand.Rand,
+) [][3]float32 {
+	x := make([][3]float32, 0, n)
+	for len(x) < n {
+		dx := [3]float32{ }
+		for k := 0; k < 3; k++ { dx[k] = (2*rng.Float32() - 1)*rMax }
+		dr2 := dist2(dx, [3]float32{ })
+		if dr2 >= rMax*rMax || dr2 < rMin*rMin { continue }
+		for k := 0; k < 3; k++ { dx[k] += cen[k] }
+		x = append(x, dx)
+	}
+	return x
+}
+
+func TestSOMasses(t *testing.T) {
+	L, n := float32(50), 2000
+	rng := rand.New(rand.NewSource(9))
+	cenA, cenB := [3]float32{ 25, 25, 25 }, [3]float32{ 28, 25, 25 }
+
+	x := uniformSphere(n, cenA, 0, 1, rng)
+	x = append(x, uniformSphere(n, cenB, 0, 1, rng)...)
+	x = append(x, uniformSphere(100, cenA, 1.2, 1.8, rng)...)
+
+	groups := make([]int32, len(x))
+	for i := range groups {
+		if i < n {
+			groups[i] = 0
+		} else if i < 2*n {
+			groups[i] = int32(n)
+		} else {
+			groups[i] = -1
+		}
+	}
+
+	rho0 := float64(n)/(4*math.Pi/3)
+	s := NewSOFinder(L, 1, float32(rho0/1600), float32(rho0/200), x, groups, 25)
+	s.DeltaVir, s.Delta, s.DeltaMean = 100, 25, true
+
+	tests := []struct{
+		overlap SOOverlap
+		m200c float32
+	} {
+		{ Strict, float32(n) },
+		{ Exclusive, float32(n + 100) },
+	}
+
+	for _, test := range tests {
+		s.Overlap = test.overlap
+		m := s.Masses(cenA, 0, 0.5)
+		if m.M200c != test.m200c {
+			t.Errorf("Overlap %d: expected M200c = %g, got %g.",
+				test.overlap, test.m200c, m.M200c)
+		}
+
+		r200c := float32(math.Cbrt(float64(test.m200c)/float64(n)*8))
+		if !almostEq(m.R200c, r200c) {
+			t.Errorf("Overlap %d: expected R200c = %g, got %g.",
+				test.overlap, r200c, m.R200c)
+		}
+		rVir := float32(math.Cbrt(float64(test.m200c)/float64(n)*16))
+		if m.MVir != test.m200c || !almostEq(m.RVir, rVir) {
+			t.Errorf("Overlap %d: expected MVir = %g and RVir = %g, got " +
+				"%g and %g.", test.overlap, test.m200c, rVir, m.MVir, m.RVir)
+		}
+		// 200 times the mean density is the same as the sphere's density,
+		// so the boundary is somewhere inside of it.
+		if m.M200m <= 0 || m.M200m >= float32(n) || m.R200m >= 1 {
+			t.Errorf("Overlap %d: M200m = %g and R200m = %g should be " +
+				"inside the sphere.", test.overlap, m.M200m, m.R200m)
+		}
+		if m.MDelta != m.M200c || m.RDelta != m.R200c {
+			t.Errorf("Overlap %d: 25 times the mean density should match " +
+				"200c, but got MDelta = %g, M200c = %g.",
+				test.overlap, m.MDelta, m.M200c)
+		}
+	}
+
+	s.Overlap = Inclusive
+	if m := s.Masses(cenA, 0, 0.5); m.M200c <= float32(n + 100) {
+		t.Errorf("Inclusive M200c = %g should include particles from the " +
+			"neighbouring group.", m.M200c)
+	}
+}
+
+func TestSOMassesZeroDensity(t *testing.T) {
+	L, n := float32(50), 2000
+	rng := rand.New(rand.NewSource(10))
+	cen := [3]float32{ 25, 25, 25 }
+	x := uniformSphere(n, cen, 0, 1, rng)
+
+	rho0 := float64(n)/(4*math.Pi/3)
+	s := NewSOFinder(L, 1, float32(rho0/400), float32(rho0/3200), x, nil, 25)
+	if exp := float32(BryanNormanDeltaVir(1.0/8)); s.DeltaVir != exp {
+		t.Errorf("Expected DeltaVir = %g, got %g.", exp, s.DeltaVir)
+	}
+
+	// A zero target density is skipped instead of searching the whole box.
+	s.DeltaVir = 0
+	m := s.Masses(cen, 0, 0.5)
+	if m.MVir != 0 || m.RVir != 0 {
+		t.Errorf("Expected MVir = RVir = 0, got %g and %g.", m.MVir, m.RVir)
+	}
+	if m.M200c != float32(n) {
+		t.Errorf("Expected M200c = %d, got %g.", n, m.M200c)
+	}
+}
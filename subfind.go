@@ -0,0 +1,267 @@
+package symfof
+
+import (
+	"math"
+	"slices"
+)
+
+// Subhalo is a gravitationally bound overdensity inside a FOF group.
+type Subhalo struct {
+	// Group is the label of the parent FOF group. Parent is the index of the
+	// smallest subhalo in the same group which this subhalo sits inside of,
+	// or -1 if there isn't one.
+	Group, Parent int32
+	// Members gives the indices of the bound member particles and Mass is
+	// their total mass.
+	Members []int32
+	Mass    float32
+}
+
+// SubhaloFinder finds subhalos inside FOF groups with the SUBFIND algorithm
+// (Springel et al. 2001). Particles are added in order of decreasing local
+// density. Each one either starts a new candidate at a density peak, joins
+// the candidate of its two nearest denser neighbours, or, if those
+// neighbours are in different candidates, marks a saddle point where the
+// smaller candidate is recorded and the two are merged. Every recorded candidate is
+// unbound starting with the smallest, and each particle is given to the
+// smallest bound subhalo that contains it.
+type SubhaloFinder struct {
+	// Unbinder removes unbound particles from candidates. It also sets the
+	// box width and particle mass.
+	Unbinder *Unbinder
+	// NDens is the number of neighbours used to estimate densities and
+	// NNgb is the number of neighbours searched for denser particles.
+	NDens, NNgb int
+	// NMin is the smallest number of bound particles in a subhalo.
+	NMin int
+	// NGrid is the number of grid cells used by neighbour searches.
+	NGrid int
+}
+
+// NewSubhaloFinder creates a SubhaloFinder for a periodic box of width L
+// with particle mass mp and softening length eps, using the SUBFIND default
+// parameters.
+func NewSubhaloFinder(L, mp, eps float32) *SubhaloFinder {
+	return &SubhaloFinder{
+		Unbinder: NewUnbinder(L, mp, eps),
+		NDens: 32, NNgb: 20, NMin: 20, NGrid: 50,
+	}
+}
+
+// FindAll finds the subhalos in every FOF group, where groups[i] is the
+// group of p[i] or -1 if p[i] isn't in a group. The subhalos of each group
+// are ordered from largest to smallest, so the first subhalo of every group
+// is its main subhalo. Groups are in Catalog order.
+func (sf *SubhaloFinder) FindAll(p []Particle, groups []int32) [][]Subhalo {
+	c := NewCatalog(sf.Unbinder.L, sf.Unbinder.Mp, groups, p)
+	out := make([][]Subhalo, len(c.Halos))
+	for i, h := range c.Halos {
+		out[i] = sf.Find(p, h.Root, c.Members(int32(i)))
+	}
+	return out
+}
+
+// subfindCandidate is a candidate subhalo. Its members are the first n
+// particles of a linked list starting at head.
+type subfindCandidate struct {
+	head, n int32
+}
+
+// Find finds the subhalos of the FOF group with label group whose members
+// are the given indices of p.
+func (sf *SubhaloFinder) Find(
+	p []Particle, group int32, members []int32,
+) []Subhalo {
+	n := int32(len(members))
+	if n == 0 { return []Subhalo{ } }
+
+	x := sf.localPositions(p, members)
+	f := NewFinder(sf.Unbinder.L, x, sf.NGrid)
+	rho, ngb, nNgb := sf.densities(f, x)
+
+	order := make([]int32, n)
+	for i := range order { order[i] = int32(i) }
+	slices.SortFunc(order, func(i, j int32) int {
+		if rho[i] > rho[j] {
+			return -1
+		} else if rho[i] < rho[j] {
+			return +1
+		}
+		return int(i - j)
+	})
+
+	// Candidates are stored as linked lists so that a list which is
+	// appended to another list remains a valid prefix of itself.
+	next := make([]int32, n)
+	head, tail, length := make([]int32, n), make([]int32, n), make([]int32, n)
+	added := make([]bool, n)
+	uf := NewUnionFinder(n)
+	cands := []subfindCandidate{ }
+
+	attach := func(root, i int32) {
+		next[tail[root]], tail[root] = i, i
+		h, t, l := head[root], i, length[root] + 1
+		uf.Union(root, i)
+		root = uf.Find(root)
+		head[root], tail[root], length[root] = h, t, l
+	}
+
+	for _, i := range order {
+		added[i] = true
+		next[i], head[i], tail[i], length[i] = listEnd, i, i, 1
+
+		rootA, rootB := int32(-1), int32(-1)
+		for _, j := range ngb[int(i)*nNgb: int(i+1)*nNgb] {
+			if j == -1 { break }
+			if !added[j] || j == i { continue }
+			if rootA == -1 {
+				rootA = uf.Find(j)
+			} else {
+				rootB = uf.Find(j)
+				break
+			}
+		}
+
+		switch {
+		case rootA == -1:
+			// A density peak.
+		case rootB == -1 || rootA == rootB:
+			attach(rootA, i)
+		default:
+			// A saddle point. The larger structure keeps growing and will
+			// be recorded later, so only the smaller one is a candidate.
+			big, small := rootA, rootB
+			if length[big] < length[small] { big, small = small, big }
+			if length[small] >= int32(sf.NMin) {
+				c := subfindCandidate{ head[small], length[small] }
+				cands = append(cands, c)
+			}
+			next[tail[big]] = head[small]
+			h, t, l := head[big], tail[small], length[big] + length[small]
+			uf.Union(big, small)
+			root := uf.Find(big)
+			head[root], tail[root], length[root] = h, t, l
+			attach(root, i)
+		}
+	}
+
+	// Chain the final structures together so that every candidate is a
+	// contiguous range of the chain.
+	rank, chain := make([]int32, n), make([]int32, 0, n)
+	for i := int32(0); i < n; i++ {
+		if uf.Find(i) != i { continue }
+		for j := head[i]; j != listEnd; j = next[j] {
+			rank[j] = int32(len(chain))
+			chain = append(chain, j)
+		}
+	}
+	// The whole group is the last candidate, which becomes the main
+	// subhalo.
+	cands = append(cands, subfindCandidate{ chain[0], n })
+	slices.SortStableFunc(cands, func(c1, c2 subfindCandidate) int {
+		return int(c1.n - c2.n)
+	})
+
+	return sf.unbindCandidates(p, group, members, cands, rank, chain)
+}
+
+// unbindCandidates unbinds candidates from smallest to largest. Particles
+// which are bound to a subhalo are removed from every larger candidate.
+func (sf *SubhaloFinder) unbindCandidates(
+	p []Particle, group int32, members []int32,
+	cands []subfindCandidate, rank, chain []int32,
+) []Subhalo {
+	claimed := make([]bool, len(members))
+	subs := []Subhalo{ }
+	lo, hi := []int32{ }, []int32{ }
+
+	for _, c := range cands {
+		start := rank[c.head]
+		idx := []int32{ }
+		for _, j := range chain[start: start + c.n] {
+			if !claimed[j] { idx = append(idx, members[j]) }
+		}
+		if len(idx) < sf.NMin { continue }
+
+		bound, mass := sf.Unbinder.Unbind(p, idx)
+		if len(bound) < sf.NMin { continue }
+
+		subs = append(subs, Subhalo{
+			Group: group, Parent: -1, Members: bound, Mass: mass,
+		})
+		lo, hi = append(lo, start), append(hi, start + c.n)
+
+		local := map[int32]bool{ }
+		for _, j := range bound { local[j] = true }
+		for _, j := range chain[start: start + c.n] {
+			if local[members[j]] { claimed[j] = true }
+		}
+	}
+
+	// Parents are the smallest larger candidate that contains a subhalo.
+	// Reversing puts the main subhalo first.
+	nSub := int32(len(subs))
+	for i := int32(0); i < nSub; i++ {
+		for j := i + 1; j < nSub; j++ {
+			if lo[j] <= lo[i] && hi[j] >= hi[i] {
+				subs[i].Parent = nSub - 1 - j
+				break
+			}
+		}
+	}
+	slices.Reverse(subs)
+
+	return subs
+}
+
+// localPositions returns the positions of the members shifted so that the
+// group is contiguous and centered in the box.
+func (sf *SubhaloFinder) localPositions(
+	p []Particle, members []int32,
+) [][3]float32 {
+	L := sf.Unbinder.L
+	ref := p[members[0]].X
+	x := make([][3]float32, len(members))
+	for i, j := range members {
+		for k := 0; k < 3; k++ {
+			x[i][k] = Bound(SymBound(p[j].X[k] - ref[k], L) + L/2, L)
+		}
+	}
+	return x
+}
+
+// densities estimates the density around each point from the distance to
+// its NDens-th nearest neighbour. It also returns the nNgb nearest
+// neighbours of each point in a flat array, sorted by distance. Missing
+// neighbours are -1.
+func (sf *SubhaloFinder) densities(
+	f *Finder, x [][3]float32,
+) (rho []float32, ngb []int32, nNgb int) {
+	k := sf.NDens
+	if k > len(x) - 1 { k = len(x) - 1 }
+	nNgb = sf.NNgb
+	if nNgb > k { nNgb = k }
+	if nNgb < 1 { nNgb = 1 }
+
+	rho = make([]float32, len(x))
+	ngb = make([]int32, len(x)*nNgb)
+	for i := range ngb { ngb[i] = -1 }
+	if k < 1 { return rho, ngb, nNgb }
+
+	for i := range x {
+		idx, dist := f.FindKNN(x[i], k + 1)
+		h := float64(dist[len(dist) - 1])
+		mass := float64(k)*float64(sf.Unbinder.Mp)
+		rho[i] = float32(mass/(4*math.Pi/3*h*h*h))
+
+		n := 0
+		for _, j := range idx {
+			if j == int32(i) { continue }
+			if n == nNgb { break }
+			ngb[i*nNgb + n] = j
+			n++
+		}
+	}
+
+	return rho, ngb, nNgb
+}
@@ -0,0 +1,76 @@
+package symfof
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// isothermalClump creates n particles in a sphere of radius r with a
+// density profile proportional to 1/r^2 and uniform random velocities with
+// dispersion sigma along each axis.
+func isothermalClump(
+	n int, cen [3]float32, r, sigma float32, rng *rand.Rand,
+) []Particle {
+	x := uniformSphere(n, [3]float32{ }, 0.999, 1, rng)
+	p := make([]Particle, n)
+	for i := range p {
+		ri := r*rng.Float32()
+		for k := 0; k < 3; k++ {
+			p[i].X[k] = cen[k] + x[i][k]*ri
+			p[i].V[k] = (2*rng.Float32() - 1)*sigma*1.732
+		}
+	}
+	return p
+}
+
+func TestSubhaloFinder(t *testing.T) {
+	L, nHost, nSub := float32(50), 2000, 200
+	rng := rand.New(rand.NewSource(10))
+
+	p := isothermalClump(nHost, [3]float32{ 49.5, 25, 25 }, 2, 20, rng)
+	subClump := isothermalClump(nSub, [3]float32{ 0.5, 25, 25 }, 0.2, 3, rng)
+	p = append(p, subClump...)
+	for i := range p { p[i].ID = uint64(i) }
+
+	groups := make([]int32, len(p))
+	sf := NewSubhaloFinder(L, 1, 0.005)
+	subs := sf.FindAll(p, groups)
+
+	if len(subs) != 1 {
+		t.Fatalf("Expected one group, got %d.", len(subs))
+	}
+	if len(subs[0]) != 2 {
+		t.Fatalf("Expected two subhalos, got %d.", len(subs[0]))
+	}
+
+	main, sub := subs[0][0], subs[0][1]
+	if main.Parent != -1 || sub.Parent != 0 {
+		t.Errorf("Expected parents -1 and 0, got %d and %d.",
+			main.Parent, sub.Parent)
+	}
+	if main.Group != 0 || sub.Group != 0 {
+		t.Errorf("Expected subhalos to be in group 0, got %d and %d.",
+			main.Group, sub.Group)
+	}
+
+	nSubMembers := 0
+	for _, j := range sub.Members {
+		if j >= int32(nHost) { nSubMembers++ }
+	}
+	if nSubMembers < nSub*9/10 || len(sub.Members) > nSub*11/10 {
+		t.Errorf("Subhalo has %d members, %d of which are from the " +
+			"subhalo clump.", len(sub.Members), nSubMembers)
+	}
+	if len(main.Members) < nHost*9/10 || main.Mass != float32(len(main.Members)) {
+		t.Errorf("Main subhalo has %d members and mass %g.",
+			len(main.Members), main.Mass)
+	}
+
+	seen := map[int32]bool{ }
+	for _, s := range subs[0] {
+		for _, j := range s.Members {
+			if seen[j] { t.Errorf("Particle %d is in two subhalos.", j) }
+			seen[j] = true
+		}
+	}
+}
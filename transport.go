@@ -0,0 +1,233 @@
+package symfof
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Transport lets a group of cooperating processes exchange messages. Each
+// process has a rank in [0, Size()). Messages between any two ranks arrive
+// in the order they were sent.
+type Transport interface {
+	Rank() int
+	Size() int
+	// Send sends a message to the process with the given rank. Send should
+	// not block waiting for the other process to call Recv.
+	Send(to int, msg []byte) error
+	// Recv receives the next message sent by the process with the given
+	// rank.
+	Recv(from int) ([]byte, error)
+	Close() error
+}
+
+const (
+	// socketDialTimeout is how long a SocketTransport waits for the other
+	// processes to start listening.
+	socketDialTimeout = 30*time.Second
+	// socketAcceptTimeout is how long a SocketTransport waits for the
+	// higher ranks to connect to it.
+	socketAcceptTimeout = 2*socketDialTimeout
+	// socketInboxSize is the number of messages that can be waiting from a
+	// single process before that process's Sends start blocking.
+	socketInboxSize = 64
+	// socketMaxMessage is the longest message, in bytes, that a
+	// SocketTransport will accept. Longer lengths are treated as corrupt.
+	socketMaxMessage = 1 << 34
+)
+
+// SocketTransport is a Transport which connects every pair of processes with
+// a stream socket, e.g. TCP or Unix domain sockets.
+type SocketTransport struct {
+	rank  int
+	ln    net.Listener
+	conns []net.Conn
+	inbox []chan []byte
+	// errs holds the error that stopped each connection's reader.
+	errs  []error
+	errMu sync.Mutex
+}
+
+var _ Transport = &SocketTransport{ }
+
+// ListenSocketTransport starts listening on addrs[rank] and connects to every
+// other address in addrs. network is any stream network accepted by
+// net.Listen, like "tcp" or "unix".
+func ListenSocketTransport(
+	network string, addrs []string, rank int,
+) (*SocketTransport, error) {
+	ln, err := net.Listen(network, addrs[rank])
+	if err != nil { return nil, err }
+	return NewSocketTransport(ln, network, addrs, rank)
+}
+
+// NewSocketTransport connects to every other address in addrs using a
+// listener which is already listening on addrs[rank]. Lower ranks are dialed
+// and higher ranks are accepted.
+func NewSocketTransport(
+	ln net.Listener, network string, addrs []string, rank int,
+) (*SocketTransport, error) {
+	t := &SocketTransport{
+		rank: rank, ln: ln,
+		conns: make([]net.Conn, len(addrs)),
+		inbox: make([]chan []byte, len(addrs)),
+		errs: make([]error, len(addrs)),
+	}
+
+	acceptErr := make(chan error, 1)
+	go func() { acceptErr <- t.accept(len(addrs) - rank - 1) }()
+
+	for j := 0; j < rank; j++ {
+		conn, err := dialRetry(network, addrs[j])
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		err = binary.Write(conn, binary.LittleEndian, uint32(rank))
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		t.conns[j] = conn
+	}
+
+	var err error
+	select {
+	case err = <-acceptErr:
+	case <-time.After(socketAcceptTimeout):
+		// Closing the listener stops the Accept that is waiting.
+		t.ln.Close()
+		<-acceptErr
+		err = fmt.Errorf("Rank %d timed out waiting for higher ranks to " +
+			"connect.", rank)
+	}
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	for j, conn := range t.conns {
+		if conn == nil { continue }
+		t.inbox[j] = make(chan []byte, socketInboxSize)
+		go t.read(j)
+	}
+
+	return t, nil
+}
+
+// accept accepts n connections, each of which starts with the rank of the
+// process on the other end.
+func (t *SocketTransport) accept(n int) error {
+	for i := 0; i < n; i++ {
+		conn, err := t.ln.Accept()
+		if err != nil { return err }
+
+		// Don't wait forever on a connection that never sends its rank.
+		conn.SetReadDeadline(time.Now().Add(socketDialTimeout))
+		var rank uint32
+		err = binary.Read(conn, binary.LittleEndian, &rank)
+		if err == nil { err = conn.SetReadDeadline(time.Time{ }) }
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		if int(rank) <= t.rank || int(rank) >= len(t.conns) ||
+			t.conns[rank] != nil {
+			conn.Close()
+			return fmt.Errorf("Rank %d got an unexpected connection from " +
+				"rank %d.", t.rank, rank)
+		}
+		t.conns[rank] = conn
+	}
+	return nil
+}
+
+// dialRetry dials addr until it succeeds or socketDialTimeout passes.
+func dialRetry(network, addr string) (net.Conn, error) {
+	start := time.Now()
+	for {
+		conn, err := net.Dial(network, addr)
+		if err == nil { return conn, nil }
+		if time.Since(start) > socketDialTimeout { return nil, err }
+		time.Sleep(10*time.Millisecond)
+	}
+}
+
+// read reads length-prefixed messages from rank j into its inbox.
+func (t *SocketTransport) read(j int) {
+	defer close(t.inbox[j])
+	for {
+		var n uint64
+		err := binary.Read(t.conns[j], binary.LittleEndian, &n)
+		if err == nil && n > socketMaxMessage {
+			err = fmt.Errorf("Rank %d sent a message of %d bytes to rank " +
+				"%d, but the limit is %d.", j, n, t.rank,
+				uint64(socketMaxMessage))
+		}
+		if err == nil {
+			msg := make([]byte, n)
+			if _, err = io.ReadFull(t.conns[j], msg); err == nil {
+				t.inbox[j] <- msg
+				continue
+			}
+		}
+
+		t.errMu.Lock()
+		t.errs[j] = err
+		t.errMu.Unlock()
+		return
+	}
+}
+
+func (t *SocketTransport) Rank() int { return t.rank }
+
+func (t *SocketTransport) Size() int { return len(t.conns) }
+
+func (t *SocketTransport) Send(to int, msg []byte) error {
+	if to == t.rank {
+		return fmt.Errorf("Rank %d cannot send a message to itself.", to)
+	}
+	if uint64(len(msg)) > socketMaxMessage {
+		return fmt.Errorf("Rank %d cannot send a message of %d bytes, " +
+			"since the limit is %d.", t.rank, len(msg),
+			uint64(socketMaxMessage))
+	}
+	// A single Write keeps concurrent Sends from interleaving.
+	frame := make([]byte, 8 + len(msg))
+	binary.LittleEndian.PutUint64(frame, uint64(len(msg)))
+	copy(frame[8:], msg)
+	_, err := t.conns[to].Write(frame)
+	return err
+}
+
+func (t *SocketTransport) Recv(from int) ([]byte, error) {
+	if from == t.rank {
+		return nil, fmt.Errorf("Rank %d cannot receive a message from " +
+			"itself.", from)
+	}
+	msg, ok := <-t.inbox[from]
+	if ok { return msg, nil }
+
+	t.errMu.Lock()
+	defer t.errMu.Unlock()
+	if t.errs[from] == nil || t.errs[from] == io.EOF {
+		return nil, fmt.Errorf("Rank %d closed its connection to rank %d.",
+			from, t.rank)
+	}
+	return nil, t.errs[from]
+}
+
+func (t *SocketTransport) Close() error {
+	var err error
+	for _, conn := range t.conns {
+		if conn == nil { continue }
+		if cerr := conn.Close(); cerr != nil && err == nil { err = cerr }
+	}
+	if t.ln != nil {
+		if lerr := t.ln.Close(); lerr != nil && err == nil { err = lerr }
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package symfof
+
+import (
+	"math"
+	"sort"
+)
+
+// Unbinder iteratively removes gravitationally unbound particles from
+// groups. Potentials are computed in code units where G = 1, by direct
+// summation for small groups and with a Barnes-Hut octree for large ones.
+// Kinetic energies are measured in the frame of the bound particles' bulk
+// velocity.
+//
+// Unbinder holds internal buffers that are reused between calls, so the same
+// Unbinder can't be used by multiple goroutines at once.
+type Unbinder struct {
+	// L is the width of the periodic box and Mp is the mass of a single
+	// particle.
+	L, Mp float32
+	// Eps is the Plummer softening length.
+	Eps float32
+	// Theta is the opening angle used by the octree.
+	Theta float32
+	// DirectMax is the largest number of particles which have their
+	// potentials computed by direct summation.
+	DirectMax int
+	// MaxIter is the maximum number of unbinding passes. If zero, passes
+	// continue until no more particles are removed.
+	MaxIter int
+
+	// All of these are internal buffers that are meaningless to users.
+	x, v   [][3]float32
+	phi, e []float32
+	idx    []int32
+	tree   octree
+}
+
+// NewUnbinder creates an Unbinder with reasonable default values for Theta
+// and DirectMax.
+func NewUnbinder(L, mp, eps float32) *Unbinder {
+	return &Unbinder{ L: L, Mp: mp, Eps: eps, Theta: 0.5, DirectMax: 1000 }
+}
+
+// Unbind removes unbound particles from the group whose members are the
+// given indices of p. It returns the indices of the bound members, sorted
+// from most to least bound, and their total mass.
+func (u *Unbinder) Unbind(p []Particle, members []int32) ([]int32, float32) {
+	if len(members) == 0 { return []int32{ }, 0 }
+
+	// Move the particles into a frame where the group doesn't cross the
+	// box edge.
+	ref := p[members[0]].X
+	u.x, u.v, u.idx, u.e = u.x[:0], u.v[:0], u.idx[:0], u.e[:0]
+	for _, j := range members {
+		x := [3]float32{ }
+		for k := 0; k < 3; k++ {
+			x[k] = SymBound(p[j].X[k] - ref[k], u.L)
+		}
+		u.x = append(u.x, x)
+		u.v = append(u.v, p[j].V)
+		u.idx = append(u.idx, j)
+		u.e = append(u.e, 0)
+	}
+
+	for iter := 0; u.MaxIter == 0 || iter < u.MaxIter; iter++ {
+		u.potential()
+
+		vBulk := [3]float64{ }
+		for i := range u.v {
+			for k := 0; k < 3; k++ { vBulk[k] += float64(u.v[i][k]) }
+		}
+		for k := 0; k < 3; k++ { vBulk[k] /= float64(len(u.v)) }
+
+		// Compact the bound particles to the front of the buffers.
+		n := 0
+		for i := range u.x {
+			ke := float64(0)
+			for k := 0; k < 3; k++ {
+				dv := float64(u.v[i][k]) - vBulk[k]
+				ke += dv*dv/2
+			}
+			e := ke + float64(u.phi[i])
+			if e >= 0 { continue }
+
+			u.x[n], u.v[n], u.idx[n] = u.x[i], u.v[i], u.idx[i]
+			u.e[n] = float32(e)
+			n++
+		}
+
+		removed := n < len(u.x)
+		u.x, u.v, u.idx, u.e = u.x[:n], u.v[:n], u.idx[:n], u.e[:n]
+		if !removed || n == 0 { break }
+	}
+
+	bound := make([]int32, len(u.idx))
+	copy(bound, u.idx)
+	sort.Sort(&energyOrder{ bound, u.e })
+	return bound, u.Mp*float32(len(bound))
+}
+
+// energyOrder sorts particle indices by their energies.
+type energyOrder struct {
+	idx []int32
+	e   []float32
+}
+
+func (o *energyOrder) Len() int { return len(o.idx) }
+
+func (o *energyOrder) Less(i, j int) bool { return o.e[i] < o.e[j] }
+
+func (o *energyOrder) Swap(i, j int) {
+	o.idx[i], o.idx[j] = o.idx[j], o.idx[i]
+	o.e[i], o.e[j] = o.e[j], o.e[i]
+}
+
+// potential computes the potential of every particle in u.x.
+func (u *Unbinder) potential() {
+	n := len(u.x)
+	if cap(u.phi) < n { u.phi = make([]float32, n) }
+	u.phi = u.phi[:n]
+
+	if n > u.DirectMax {
+		u.tree.build(u.x, u.Mp)
+		for i := range u.phi {
+			u.phi[i] = u.tree.potential(int32(i), u.Mp, u.Eps, u.Theta)
+		}
+		return
+	}
+
+	phi := make([]float64, n)
+	eps2 := u.Eps*u.Eps
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			dr2 := dist2(u.x[i], u.x[j])
+			dphi := float64(u.Mp)/math.Sqrt(float64(dr2 + eps2))
+			phi[i] -= dphi
+			phi[j] -= dphi
+		}
+	}
+	for i := range phi { u.phi[i] = float32(phi[i]) }
+}
+
+// Unbind runs the Unbinder on every halo in the catalog and returns the
+// indices of each halo's bound members, from most to least bound, and their
+// total mass.
+func (c *Catalog) Unbind(u *Unbinder, p []Particle) ([][]int32, []float32) {
+	bound, mass := make([][]int32, len(c.Halos)), make([]float32, len(c.Halos))
+	for i := range c.Halos {
+		bound[i], mass[i] = u.Unbind(p, c.Members(int32(i)))
+	}
+	return bound, mass
+}
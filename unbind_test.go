@@ -0,0 +1,121 @@
+package symfof
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// boundClump creates n particles in a cold sphere of radius one centered on
+// the corner of the box, followed by nFast particles mixed into the sphere
+// with large velocities.
+func boundClump(n, nFast int, L float32) []Particle {
+	rng := rand.New(rand.NewSource(7))
+	p := make([]Particle, n + nFast)
+	for i := range p {
+		p[i].ID = uint64(i)
+		for {
+			for k := 0; k < 3; k++ { p[i].X[k] = 2*rng.Float32() - 1 }
+			if dist2(p[i].X, [3]float32{ }) < 1 { break }
+		}
+		for k := 0; k < 3; k++ {
+			p[i].X[k] = Bound(p[i].X[k], L)
+			p[i].V[k] = rng.Float32() - 0.5
+		}
+		if i >= n { p[i].V[0] += 1000 }
+	}
+	return p
+}
+
+// bindingEnergies computes the energy of every member of a group by direct
+// summation, in the frame of the group's bulk velocity.
+func bindingEnergies(p []Particle, members []int32, L, eps float32) []float64 {
+	vBulk := [3]float64{ }
+	for _, j := range members {
+		for k := 0; k < 3; k++ {
+			vBulk[k] += float64(p[j].V[k])/float64(len(members))
+		}
+	}
+
+	e := make([]float64, len(members))
+	for a, i := range members {
+		for k := 0; k < 3; k++ {
+			dv := float64(p[i].V[k]) - vBulk[k]
+			e[a] += dv*dv/2
+		}
+		for _, j := range members {
+			if i == j { continue }
+			dr2 := float64(eps*eps)
+			for k := 0; k < 3; k++ {
+				dx := float64(SymBound(p[i].X[k] - p[j].X[k], L))
+				dr2 += dx*dx
+			}
+			e[a] -= 1/math.Sqrt(dr2)
+		}
+	}
+	return e
+}
+
+func TestUnbind(t *testing.T) {
+	L, n, nFast := float32(50), 500, 10
+	p := boundClump(n, nFast, L)
+	members := make([]int32, len(p))
+	for i := range members { members[i] = int32(i) }
+
+	for _, directMax := range []int{ 0, 10000 } {
+		u := NewUnbinder(L, 1, 0.01)
+		u.DirectMax = directMax
+		bound, mass := u.Unbind(p, members)
+
+		if len(bound) != n || mass != float32(n) {
+			t.Errorf("DirectMax = %d: expected %d bound particles with mass " +
+				"%d, got %d with mass %g.", directMax, n, n, len(bound), mass)
+		}
+		for _, j := range bound {
+			if j >= int32(n) {
+				t.Errorf("DirectMax = %d: fast particle %d is bound.",
+					directMax, j)
+			}
+		}
+
+		// Bound particles come back from most to least bound, up to the
+		// accuracy of the octree.
+		e := bindingEnergies(p, bound, L, 0.01)
+		for i := 1; i < len(e); i++ {
+			if e[i] < e[i-1] - 1e-2*math.Abs(e[i-1]) {
+				t.Errorf("DirectMax = %d: bound particle %d has energy " +
+					"%g, but particle %d has energy %g.", directMax,
+					i - 1, e[i-1], i, e[i])
+				break
+			}
+		}
+	}
+
+	// A single particle can't be bound to anything.
+	u := NewUnbinder(L, 1, 0.01)
+	if bound, mass := u.Unbind(p, []int32{ 3 }); len(bound) != 0 || mass != 0 {
+		t.Errorf("Expected a lone particle to be unbound, got %d.", bound)
+	}
+}
+
+func TestOctreePotential(t *testing.T) {
+	x := randomPoints(3000, 10, 8)
+	tree := &octree{ }
+	tree.build(x, 1)
+
+	eps := float32(0.01)
+	for i := int32(0); i < int32(len(x)); i += 97 {
+		direct := float64(0)
+		for j := range x {
+			if int32(j) == i { continue }
+			dr2 := dist2(x[i], x[j])
+			direct -= 1/math.Sqrt(float64(dr2 + eps*eps))
+		}
+
+		phi := float64(tree.potential(i, 1, eps, 0.5))
+		if math.Abs(phi/direct - 1) > 0.01 {
+			t.Errorf("Particle %d: tree potential %g, direct potential %g.",
+				i, phi, direct)
+		}
+	}
+}
@@ -1,25 +1,43 @@
 package symfof
 
-type UnionFinder struct {
-	Parent []int32
-	Size []int32
-	NGroup int32
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// UnionFinderOf is a union-find structure over the elements [0, n). The
+// index type limits n, so int64 is needed above about 2.1 billion elements.
+type UnionFinderOf[I Index] struct {
+	Parent []I
+	Size []I
+	NGroup I
 }
 
+// UnionFinder is a UnionFinderOf with int32 indices.
+type UnionFinder = UnionFinderOf[int32]
+
 func NewUnionFinder(n int32) *UnionFinder {
-	uf := &UnionFinder{
-		Parent: make([]int32, n),
-		Size: make([]int32, n),
+	return NewUnionFinderOf(n)
+}
+
+// NewUnionFinderOf creates a UnionFinderOf with n elements, each in its own
+// group.
+func NewUnionFinderOf[I Index](n I) *UnionFinderOf[I] {
+	uf := &UnionFinderOf[I]{
+		Parent: make([]I, n),
+		Size: make([]I, n),
+		NGroup: n,
 	}
 	for i := range uf.Parent {
-		uf.Parent[i] = int32(i)
+		uf.Parent[i] = I(i)
 		uf.Size[i] = 1
 	}
 
 	return uf
 }
 
-func (uf *UnionFinder) Find(i int32) int32 {
+func (uf *UnionFinderOf[I]) Find(i I) I {
 	j := i
 	for uf.Parent[j] != j {
 		j = uf.Parent[j]
@@ -34,9 +52,16 @@ func (uf *UnionFinder) Find(i int32) int32 {
 	return root
 }
 
-func (uf *UnionFinder) Union(i, j int32) {
+func (uf *UnionFinderOf[I]) Union(i, j I) {
+	if uf.union(i, j) { uf.NGroup-- }
+}
+
+// union links the groups of i and j without updating NGroup and returns true
+// if they were previously separate groups. Goroutines may call union at the
+// same time as long as they never touch the same groups.
+func (uf *UnionFinderOf[I]) union(i, j I) bool {
 	rooti, rootj := uf.Find(i), uf.Find(j)
-	if rooti == rootj { return }
+	if rooti == rootj { return false }
 	sizei, sizej := uf.Size[rooti], uf.Size[rootj]
 	if sizei < sizej {
 		uf.Parent[rooti] = rootj
@@ -45,5 +70,99 @@ func (uf *UnionFinder) Union(i, j int32) {
 		uf.Parent[rootj] = rooti
 		uf.Size[rooti] = sizei + sizej
 	}
-	uf.NGroup--
+	return true
+}
+
+// Grow adds n new elements to uf, each in its own group.
+func (uf *UnionFinderOf[I]) Grow(n I) {
+	for i := I(0); i < n; i++ {
+		uf.Parent = append(uf.Parent, I(len(uf.Parent)))
+		uf.Size = append(uf.Size, 1)
+	}
+	uf.NGroup += n
+}
+
+// Merge unions every group in other into uf. Element i of other is element
+// remap[i] of uf. If remap is nil, elements have the same index in both.
+func (uf *UnionFinderOf[I]) Merge(other *UnionFinderOf[I], remap []I) {
+	for i := range other.Parent {
+		j := other.Find(I(i))
+		if j == I(i) { continue }
+		if remap == nil {
+			uf.Union(I(i), j)
+		} else {
+			uf.Union(remap[i], remap[j])
+		}
+	}
+}
+
+// MarshalBinary encodes uf in a little-endian binary format.
+func (uf *UnionFinderOf[I]) MarshalBinary() ([]byte, error) {
+	buf := &bytes.Buffer{ }
+	header := [2]I{ I(len(uf.Parent)), uf.NGroup }
+	for _, data := range []any{ header, uf.Parent, uf.Size } {
+		if err := binary.Write(buf, binary.LittleEndian, data); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a UnionFinderOf written by MarshalBinary with the
+// same index type. An error is returned if data is truncated or doesn't
+// describe a valid union-find, so it is safe to use on untrusted input.
+func (uf *UnionFinderOf[I]) UnmarshalBinary(data []byte) error {
+	buf := bytes.NewReader(data)
+	header := [2]I{ }
+	if err := binary.Read(buf, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+
+	n, nGroup := header[0], header[1]
+	size := int64(binary.Size(n))
+	if n < 0 || int64(n) > int64(buf.Len())/(2*size) {
+		return fmt.Errorf("UnionFinder data with %d bytes left cannot " +
+			"hold %d elements.", buf.Len(), n)
+	}
+	if nGroup < 0 || nGroup > n {
+		return fmt.Errorf("UnionFinder with %d elements cannot have %d " +
+			"groups.", n, nGroup)
+	}
+
+	parent, sizes := make([]I, n), make([]I, n)
+	for _, data := range []any{ parent, sizes } {
+		if err := binary.Read(buf, binary.LittleEndian, data); err != nil {
+			return err
+		}
+	}
+	if err := checkParents(parent); err != nil { return err }
+
+	uf.Parent, uf.Size, uf.NGroup = parent, sizes, nGroup
+	return nil
+}
+
+// checkParents returns an error if parent has an index outside the array or
+// a cycle, either of which would break Find.
+func checkParents[I Index](parent []I) error {
+	// 0 is unvisited, 1 is on the current path, and 2 leads to a root.
+	state := make([]uint8, len(parent))
+	path := []I{ }
+	for i := range parent {
+		path = path[:0]
+		for j := I(i); state[j] != 2; j = parent[j] {
+			if state[j] == 1 {
+				return fmt.Errorf("UnionFinder has a cycle through %d.", j)
+			}
+			state[j] = 1
+			path = append(path, j)
+
+			if parent[j] < 0 || int64(parent[j]) >= int64(len(parent)) {
+				return fmt.Errorf("UnionFinder element %d has parent %d, " +
+					"but there are %d elements.", j, parent[j], len(parent))
+			}
+			if parent[j] == j { break }
+		}
+		for _, j := range path { state[j] = 2 }
+	}
+	return nil
 }
@@ -0,0 +1,72 @@
+package symfof
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestUnionFinderNGroup(t *testing.T) {
+	uf := NewUnionFinder(6)
+	if uf.NGroup != 6 {
+		t.Errorf("Expected 6 groups before any unions, got %d.", uf.NGroup)
+	}
+
+	unions := [][2]int32{ {0, 1}, {2, 3}, {1, 0}, {3, 1}, {4, 4} }
+	exp := []int32{ 5, 4, 4, 3, 3 }
+	for k, u := range unions {
+		uf.Union(u[0], u[1])
+		if uf.NGroup != exp[k] {
+			t.Errorf("%d) expected %d groups after Union(%d, %d), got %d.",
+				k, exp[k], u[0], u[1], uf.NGroup)
+		}
+	}
+}
+
+func TestUnionFinderUnmarshalCorrupt(t *testing.T) {
+	uf := NewUnionFinder(5)
+	uf.Union(0, 1)
+	uf.Union(3, 4)
+	data, err := uf.MarshalBinary()
+	if err != nil { t.Fatalf("MarshalBinary failed: %s", err.Error()) }
+
+	// encode writes a header, parents and sizes in MarshalBinary's format.
+	encode := func(n, nGroup int32, parent, size []int32) []byte {
+		buf := &bytes.Buffer{ }
+		binary.Write(buf, binary.LittleEndian, [2]int32{ n, nGroup })
+		binary.Write(buf, binary.LittleEndian, parent)
+		binary.Write(buf, binary.LittleEndian, size)
+		return buf.Bytes()
+	}
+	ones := []int32{ 1, 1, 1 }
+
+	tests := []struct{
+		name string
+		data []byte
+	} {
+		{ "truncated header", data[:5] },
+		{ "truncated arrays", data[:len(data) - 1] },
+		{ "huge length", encode(1 << 30, 1, ones, ones) },
+		{ "negative length", encode(-3, 1, ones, ones) },
+		{ "too many groups", encode(3, 4, []int32{ 0, 1, 2 }, ones) },
+		{ "parent out of range", encode(3, 2, []int32{ 0, 7, 2 }, ones) },
+		{ "negative parent", encode(3, 2, []int32{ 0, -1, 2 }, ones) },
+		{ "cycle", encode(3, 1, []int32{ 1, 2, 0 }, ones) },
+	}
+
+	for _, test := range tests {
+		read := &UnionFinder{ }
+		if err := read.UnmarshalBinary(test.data); err == nil {
+			t.Errorf("%s: expected an error from UnmarshalBinary.", test.name)
+		}
+	}
+
+	read := &UnionFinder{ }
+	if err := read.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %s", err.Error())
+	}
+	if read.NGroup != 3 || read.Find(1) != read.Find(0) ||
+		read.Find(4) != read.Find(3) || read.Find(0) == read.Find(3) {
+		t.Errorf("UnmarshalBinary did not restore the union-find.")
+	}
+}
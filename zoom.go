@@ -0,0 +1,169 @@
+package symfof
+
+import (
+	"math"
+	"slices"
+)
+
+// Zoom describes the particle species in a zoom-in simulation and how
+// ZoomFOF should treat groups which contain low-resolution particles.
+type Zoom struct {
+	// Mass is the mass of each particle. If Mass is nil, every particle has
+	// the same mass.
+	Mass []float32
+	// LowRes is true for low-resolution particles. If LowRes is nil,
+	// every particle which is heavier than the lightest particle is
+	// low-resolution.
+	LowRes []bool
+	// MaxFraction is the largest low-resolution mass fraction a group can
+	// have without being contaminated. Defaults to 0.
+	MaxFraction float32
+	// Drop removes contaminated groups from the output if true. Otherwise
+	// they are only flagged.
+	Drop bool
+}
+
+// Contamination describes the low-resolution particles in a single FOF
+// group.
+type Contamination struct {
+	// Root is the label of the group in the groups array returned by ZoomFOF.
+	Root int32
+	// N is the number of members and NLowRes is the number of
+	// low-resolution members.
+	N, NLowRes int32
+	// MassFraction is the fraction of the group's mass in low-resolution
+	// particles.
+	MassFraction float32
+	// X is the center of mass of the group and LowResDist is the distance
+	// from X to the nearest low-resolution particle, which may not be a
+	// member. LowResDist is +Inf if there are no low-resolution particles.
+	X          [3]float32
+	LowResDist float32
+	// Contaminated is true if MassFraction is larger than Zoom.MaxFraction.
+	Contaminated bool
+}
+
+// ZoomFOF runs FOF on a zoom-in simulation and reports the low-resolution
+// contamination of every group. contam is sorted by Root. If z.Drop is true,
+// the members and centers of contaminated groups are given a label of -1 and
+// the groups are left out of contam.
+func ZoomFOF(
+	L float32, x, cen [][3]float32, r float32, nGrid, nMin int, z *Zoom,
+) (groups, cenGroups []int32, contam []Contamination) {
+	groups, cenGroups = FOF(L, x, cen, r, nGrid, nMin)
+	lowRes := z.lowRes(len(x))
+
+	index := map[int32]int32{ }
+	for _, g := range groups {
+		if g == -1 { continue }
+		if _, ok := index[g]; !ok {
+			index[g] = int32(len(contam))
+			contam = append(contam, Contamination{ Root: g })
+		}
+	}
+	slices.SortFunc(contam, func(a, b Contamination) int {
+		return int(a.Root) - int(b.Root)
+	})
+	for i := range contam { index[contam[i].Root] = int32(i) }
+
+	// Masses and positions are accumulated relative to the root member so
+	// that groups which cross the box edge don't get split.
+	mass, lowMass := make([]float64, len(contam)), make([]float64, len(contam))
+	dx := make([][3]float64, len(contam))
+	for i, g := range groups {
+		if g == -1 { continue }
+		c := &contam[index[g]]
+		ref := x[g]
+
+		m := z.mass(i)
+		c.N++
+		mass[index[g]] += m
+		if lowRes[i] {
+			c.NLowRes++
+			lowMass[index[g]] += m
+		}
+		for k := 0; k < 3; k++ {
+			dx[index[g]][k] += m*float64(SymBound(x[i][k] - ref[k], L))
+		}
+	}
+
+	lowX := [][3]float32{ }
+	for i := range x {
+		if lowRes[i] { lowX = append(lowX, x[i]) }
+	}
+	var lowFinder *Finder
+	if len(lowX) > 0 { lowFinder = NewFinder(L, lowX, nGrid) }
+
+	for i := range contam {
+		c := &contam[i]
+		for k := 0; k < 3; k++ {
+			c.X[k] = Bound(x[c.Root][k] + float32(dx[i][k]/mass[i]), L)
+		}
+		c.MassFraction = float32(lowMass[i]/mass[i])
+		c.Contaminated = c.MassFraction > z.MaxFraction
+		c.LowResDist = nearestDist(L, c.X, lowX, lowFinder, r)
+	}
+
+	if !z.Drop { return groups, cenGroups, contam }
+
+	for i, g := range groups {
+		if g != -1 && contam[index[g]].Contaminated { groups[i] = -1 }
+	}
+	for i, g := range cenGroups {
+		if g != -1 && contam[index[g]].Contaminated { cenGroups[i] = -1 }
+	}
+	contam = slices.DeleteFunc(contam, func(c Contamination) bool {
+		return c.Contaminated
+	})
+
+	return groups, cenGroups, contam
+}
+
+// lowRes returns whether each of the n particles is low-resolution.
+func (z *Zoom) lowRes(n int) []bool {
+	if z.LowRes != nil { return z.LowRes }
+
+	lowRes := make([]bool, n)
+	if z.Mass == nil { return lowRes }
+	mMin := slices.Min(z.Mass)
+	for i := range lowRes { lowRes[i] = z.Mass[i] > mMin }
+	return lowRes
+}
+
+// mass returns the mass of particle i.
+func (z *Zoom) mass(i int) float64 {
+	if z.Mass == nil { return 1 }
+	return float64(z.Mass[i])
+}
+
+// nearestDist returns the distance from pos to the closest point in x, which
+// has been placed in the Finder f. The search starts at radius r0 and
+// doubles until it finds a point. If r0 isn't positive, the search starts at
+// the width of one of f's cells instead. +Inf is returned if x is empty.
+func nearestDist(
+	L float32, pos [3]float32, x [][3]float32, f *Finder, r0 float32,
+) float32 {
+	if len(x) == 0 { return float32(math.Inf(+1)) }
+	if !(r0 > 0) { r0 = f.g.cw[0] }
+
+	idx := []int32{ }
+	for r := r0; r < L/2 && len(idx) == 0; r *= 2 {
+		idx = f.Find(pos, r)
+	}
+	if len(idx) == 0 {
+		// Every point is too far away for the Finder, so check them all.
+		idx = make([]int32, len(x))
+		for j := range idx { idx[j] = int32(j) }
+	}
+
+	minDr2 := float32(math.Inf(+1))
+	for _, j := range idx {
+		dr2 := float32(0)
+		for k := 0; k < 3; k++ {
+			dx := SymBound(x[j][k] - pos[k], L)
+			dr2 += dx*dx
+		}
+		if dr2 < minDr2 { minDr2 = dr2 }
+	}
+	return float32(math.Sqrt(float64(minDr2)))
+}
@@ -0,0 +1,103 @@
+package symfof
+
+import (
+	"math"
+	"testing"
+)
+
+func TestZoomFOF(t *testing.T) {
+	L, r := float32(100), float32(1.5)
+	x := [][3]float32{
+		{50, 50, 50}, // Clean group
+		{51, 50, 50},
+		{52, 50, 50},
+
+		{99.5, 20, 20}, // Contaminated group crossing the box edge
+		{0.5, 20, 20},
+		{1.5, 20, 20},
+
+		{51, 56, 50}, // Isolated low-res particles
+		{80, 80, 80},
+	}
+	mass := []float32{ 1, 1, 1, 1, 1, 8, 8, 8 }
+	cen := [][3]float32{ {51, 50, 50}, {0, 20, 20} }
+
+	z := &Zoom{ Mass: mass }
+	groups, cenGroups, contam := ZoomFOF(L, x, cen, r, 20, 3, z)
+	if len(contam) != 2 {
+		t.Fatalf("Expected 2 groups, got %d.", len(contam))
+	}
+
+	clean, dirty := contam[0], contam[1]
+	if clean.Root != groups[0] {
+		clean, dirty = dirty, clean
+	}
+
+	if clean.N != 3 || clean.NLowRes != 0 || clean.MassFraction != 0 ||
+		clean.Contaminated {
+		t.Errorf("Expected clean group to be uncontaminated, got %+v.", clean)
+	}
+	if !almostEq(clean.LowResDist, 6) {
+		t.Errorf("Expected clean group's nearest low-res particle to be 6 " +
+			"away, got %g.", clean.LowResDist)
+	}
+
+	if dirty.N != 3 || dirty.NLowRes != 1 ||
+		!almostEq(dirty.MassFraction, 0.8) || !dirty.Contaminated {
+		t.Errorf("Expected dirty group to be contaminated, got %+v.", dirty)
+	}
+	// The center of mass is pulled towards the heavy particle at x = 1.5.
+	if !almostEq(dirty.X[0], 1.2) || !almostEq(dirty.LowResDist, 0.3) {
+		t.Errorf("Expected dirty group to have X[0] = 1.2 and LowResDist = " +
+			"0.3, got %g and %g.", dirty.X[0], dirty.LowResDist)
+	}
+
+	z.MaxFraction = 0.9
+	_, _, contam = ZoomFOF(L, x, cen, r, 20, 3, z)
+	for i := range contam {
+		if contam[i].Contaminated {
+			t.Errorf("Expected no contaminated groups with MaxFraction = " +
+				"0.9, but group %d is.", contam[i].Root)
+		}
+	}
+
+	z = &Zoom{ LowRes: []bool{ false, false, false, false, false, true,
+		true, true }, Drop: true }
+	groups, cenGroups, contam = ZoomFOF(L, x, cen, r, 20, 3, z)
+	if len(contam) != 1 || contam[0].Root != groups[0] {
+		t.Errorf("Expected only the clean group to remain, got %+v.", contam)
+	}
+	for i := 3; i < 6; i++ {
+		if groups[i] != -1 {
+			t.Errorf("Expected particle %d to be dropped, but it is in " +
+				"group %d.", i, groups[i])
+		}
+	}
+	if cenGroups[0] != groups[0] || cenGroups[1] != -1 {
+		t.Errorf("Expected centers to be in groups [%d -1], got %d.",
+			groups[0], cenGroups)
+	}
+}
+
+func TestZoomFOFNoLowRes(t *testing.T) {
+	x := [][3]float32{ {10, 10, 10}, {11, 10, 10} }
+	_, _, contam := ZoomFOF(50, x, nil, 1.5, 10, 2, &Zoom{ })
+	if len(contam) != 1 || contam[0].NLowRes != 0 ||
+		!math.IsInf(float64(contam[0].LowResDist), +1) {
+		t.Errorf("Expected a single clean group, got %+v.", contam)
+	}
+}
+
+func TestNearestDistNonPositiveStart(t *testing.T) {
+	L := float32(50)
+	x := [][3]float32{ {10, 10, 10}, {40, 40, 40}, {48, 2, 25} }
+	f := NewFinder(L, x, 10)
+	pos := [3]float32{ 1, 2, 25 }
+
+	// A search that started at zero would never grow.
+	for _, r0 := range []float32{ 0, -1, 1 } {
+		if dr := nearestDist(L, pos, x, f, r0); !almostEq(dr, 3) {
+			t.Errorf("r0 = %g: expected a distance of 3, got %g.", r0, dr)
+		}
+	}
+}